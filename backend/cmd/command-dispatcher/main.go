@@ -7,6 +7,7 @@ import (
 	"command-dispatcher/internal/worker"
 	"log"
 	"sync"
+	_ "time/tzdata" // Embed the time zone database so schedule time zones resolve in minimal images
 )
 
 // @title Command Dispatcher API
@@ -51,4 +52,3 @@ func main() {
 
 	log.Println("Application stopped.")
 }
-
@@ -23,6 +23,268 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/batches": {
+            "get": {
+                "description": "Retrieve command batches with the number of executions per status, with paging and sorting",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "List command batches",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Command Config ID",
+                        "name": "filter[commandConfigId]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page[number]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "page[size]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field (createdAt, status)",
+                        "name": "sort[field]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order (asc, desc)",
+                        "name": "sort[order]",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.CommandBatch"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/batches/{id}": {
+            "get": {
+                "description": "Retrieve a specific command batch with the number of executions per status.\nUse GET /executions?filter[batchId]= to list its executions.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "Get command batch by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/batches/{id}/abort": {
+            "post": {
+                "description": "Stop dispatching the following waves of a rollout. Executions already dispatched run to completion.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "Abort batch rollout",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Batch is not rolling out",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/batches/{id}/cancel": {
+            "post": {
+                "description": "Stop the rollout of the batch, if any, and cancel every execution that has not finished yet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "Cancel command batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Batch is not running or paused",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/batches/{id}/resume": {
+            "post": {
+                "description": "Dispatch the next wave of a rollout paused because a wave did not meet its thresholds",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "Resume batch rollout",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Batch is not paused",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
         "/command": {
             "get": {
                 "description": "Retrieve a list of all command configurations",
@@ -53,7 +315,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create a new command configuration with the provided details",
+                "description": "Create a new command configuration with the provided details.\nThe payload schema validates the parameters of executions, an array of objects whose values are strings:\nkeywords the validator does not support, and types other than array, object and string, are rejected.",
                 "consumes": [
                     "application/json"
                 ],
@@ -83,7 +345,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid body or payload schema",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -236,51 +498,1572 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "db.CommandConfig": {
-            "type": "object",
-            "properties": {
-                "acknowledgementTimeout": {
-                    "type": "integer"
-                },
-                "commandType": {
-                    "description": "e.g., \"rpc\", \"deviceData\", \"configuration\"",
-                    "type": "string"
-                },
-                "completionTimeout": {
-                    "type": "integer"
-                },
-                "createdAt": {
-                    "type": "string"
-                },
-                "deletedAt": {
-                    "type": "string"
-                },
-                "description": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "isAcknowledgeRequired": {
-                    "type": "boolean"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "payloadSchema": {
-                    "description": "JSON schema for validating command arguments/payload",
-                    "type": "string"
-                },
-                "updatedAt": {
-                    "type": "string"
-                }
-            }
         },
-        "models.CommandConfigCreateDTO": {
-            "type": "object",
+        "/command/{id}/execute": {
+            "post": {
+                "description": "Resolve the command configuration and enqueue its execution for the given device.\nSet deviceIds instead of deviceId to dispatch to several devices: one execution is enqueued per device\nand a db.CommandBatch tracking their progress is returned instead of the execution.\nSet groupId or selector (e.g. site=hanoi,model=x2) instead to dispatch a batch to the members of a device group\nor the devices whose tags match; the devices are resolved once, when the batch is dispatched.\nSet rollout along with a batch target to dispatch the batch in waves, pausing when a wave does not meet the thresholds.\nSet executeAt or executeIn (seconds) to schedule the execution instead of dispatching it immediately.\nSet priority to override the priority of the command configuration for this execution.\nDispatching to an offline device follows the offline policy of the command configuration:\nreject responds with 409, defer holds the execution as DEFERRED until the device comes online or the TTL expires.\nRequests repeated with the same Idempotency-Key return the original execution with 200 instead of dispatching again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "commands"
+                ],
+                "summary": "Execute a command on a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Command Config ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Command Execution",
+                        "name": "command",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CommandExecuteDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key deduplicating retried requests",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Original execution of a replayed request",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandExecution"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandExecution"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid body, unknown device or group, invalid selector, no target device or parameters not matching the payload schema",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Device offline and the offline policy is reject",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency key already used for another command, device or kind of dispatch",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/device-groups": {
+            "get": {
+                "description": "Retrieve the device groups, without their members, optionally only those a device belongs to, with paging and sorting",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "List device groups",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device the groups contain",
+                        "name": "filter[deviceId]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page[number]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "page[size]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field (name, createdAt)",
+                        "name": "sort[field]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order (asc, desc)",
+                        "name": "sort[order]",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.DeviceGroup"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a named group of registered devices. Set the groupId of a dispatch or schedule to target its members.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "Create a device group",
+                "parameters": [
+                    {
+                        "description": "Device Group",
+                        "name": "group",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceGroupCreateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.DeviceGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid body or unknown device",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Group name already used",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/device-groups/{id}": {
+            "get": {
+                "description": "Retrieve a specific device group with its member devices",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "Get device group by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.DeviceGroup"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a device group. Its devices are kept, and batches already dispatched to it are not affected.",
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "Delete device group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the name or description of a device group. Set deviceIds to replace its members.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "Update device group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Device Group Update",
+                        "name": "group",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceGroupUpdateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.DeviceGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid body or unknown device",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Group name already used",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/device-groups/{id}/devices": {
+            "post": {
+                "description": "Add registered devices to a device group. Devices already in the group are ignored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "Add devices to a group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Devices to add",
+                        "name": "members",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceGroupMembersDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.DeviceGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid body or unknown device",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/device-groups/{id}/devices/{deviceId}": {
+            "delete": {
+                "description": "Remove a device from a device group. The device itself is kept.",
+                "tags": [
+                    "device-groups"
+                ],
+                "summary": "Remove a device from a group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "deviceId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Device is not a member of the group",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/devices": {
+            "get": {
+                "description": "Retrieve the known devices with their presence, filtered by status, firmware version or tags, with paging and sorting",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "List devices",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device status (UNKNOWN, ONLINE, OFFLINE)",
+                        "name": "filter[status]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Firmware version",
+                        "name": "filter[firmwareVersion]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag selector the devices must match, e.g. site=hanoi,model=x2",
+                        "name": "filter[tags]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page[number]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "page[size]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field (id, name, status, lastSeenAt)",
+                        "name": "sort[field]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order (asc, desc)",
+                        "name": "sort[order]",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.Device"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register a device ahead of its first status message. Devices reporting their status are registered automatically.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Register a device",
+                "parameters": [
+                    {
+                        "description": "Device",
+                        "name": "device",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceCreateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.Device"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Device already registered",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}": {
+            "get": {
+                "description": "Retrieve a specific device with its presence, firmware version and reported attributes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Get device by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.Device"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a device from the registry. It is registered again when it next reports its status.",
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Delete device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the name, description, attributes or tags of a device",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Update device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Device Update",
+                        "name": "device",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeviceUpdateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.Device"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/executions": {
+            "get": {
+                "description": "Retrieve command executions filtered by device, command configuration, batch, status and issue date, with paging and sorting",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "executions"
+                ],
+                "summary": "List command executions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "filter[deviceId]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Command Config ID",
+                        "name": "filter[commandConfigId]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "filter[batchId]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Execution status",
+                        "name": "filter[status]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Issued at or after (RFC3339)",
+                        "name": "filter[issuedFrom]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Issued at or before (RFC3339)",
+                        "name": "filter[issuedTo]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page[number]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "page[size]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field (issuedAt, completedAt, status, deviceId)",
+                        "name": "sort[field]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order (asc, desc)",
+                        "name": "sort[order]",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.CommandExecution"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/executions/{id}": {
+            "get": {
+                "description": "Retrieve a specific command execution, including its status history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "executions"
+                ],
+                "summary": "Get command execution by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Execution ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandExecution"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/executions/{id}/cancel": {
+            "post": {
+                "description": "Remove a queued execution from the queue, or stop an in-flight one and ask the device to abort it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "executions"
+                ],
+                "summary": "Cancel command execution",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Execution ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandExecution"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Execution already finished",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/executions/{id}/reschedule": {
+            "post": {
+                "description": "Change when a scheduled execution is dispatched, using executeAt or executeIn (seconds)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "executions"
+                ],
+                "summary": "Reschedule command execution",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Execution ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RescheduleExecutionDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandExecution"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Execution is not scheduled anymore",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules": {
+            "get": {
+                "description": "Retrieve all command schedules with their last and next run",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Get all command schedules",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.CommandSchedule"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Dispatch a command configuration to a device every time the cron expression fires in the given time zone.\nSet groupId or selector instead of deviceId to dispatch a batch to the devices of a group or matching a tag selector,\nresolved at every run.\nSchedules are picked up by the scheduler within 30 seconds. Runs must be at least 30 seconds apart, so @every 10s is rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Create a command schedule",
+                "parameters": [
+                    {
+                        "description": "Command Schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduleCreateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandSchedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid body, cron expression, time zone, interval, target, command config or parameters",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules/{id}": {
+            "get": {
+                "description": "Retrieve a specific command schedule with its last and next run",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Get command schedule by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandSchedule"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a command schedule by ID. Executions it already dispatched are kept.",
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Delete command schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update an existing command schedule with partial data. Set enabled to false to pause it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Update command schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Command Schedule Update",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduleUpdateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.CommandSchedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "db.CommandBatch": {
+            "type": "object",
+            "properties": {
+                "commandConfigId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "deviceIds": {
+                    "description": "Target devices, resolved when the batch was dispatched",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "dispatched": {
+                    "description": "Number of devices dispatched so far, in DeviceIDs order",
+                    "type": "integer"
+                },
+                "groupId": {
+                    "description": "Device group the devices were resolved from, if any",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "idempotencyKey": {
+                    "type": "string"
+                },
+                "pauseReason": {
+                    "description": "Why the rollout paused",
+                    "type": "string"
+                },
+                "progress": {
+                    "description": "Number of executions per status",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "rollout": {
+                    "$ref": "#/definitions/db.RolloutPolicy"
+                },
+                "selector": {
+                    "description": "Tag selector the devices were resolved from, if any",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "One of the BatchStatus* constants",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "wave": {
+                    "description": "Current wave, starting at 1",
+                    "type": "integer"
+                },
+                "waveStart": {
+                    "description": "Index in DeviceIDs of the first device of the current wave",
+                    "type": "integer"
+                }
+            }
+        },
+        "db.CommandConfig": {
+            "type": "object",
+            "properties": {
+                "acknowledgementTimeout": {
+                    "type": "integer"
+                },
+                "allowConcurrent": {
+                    "description": "Opt out of running one command at a time per device",
+                    "type": "boolean"
+                },
+                "commandType": {
+                    "description": "e.g., \"rpc\", \"deviceData\", \"configuration\"",
+                    "type": "string"
+                },
+                "completionTimeout": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deferTtl": {
+                    "description": "Seconds a deferred command waits for its device to come online",
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "isAcknowledgeRequired": {
+                    "type": "boolean"
+                },
+                "isCompletionRequired": {
+                    "description": "Pointer so an explicit false is not replaced by the column default",
+                    "type": "boolean"
+                },
+                "maxRetries": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "offlinePolicy": {
+                    "description": "One of the OfflinePolicy* constants",
+                    "type": "string"
+                },
+                "payloadSchema": {
+                    "description": "JSON schema for validating command arguments/payload",
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "One of the Priority* constants",
+                    "type": "string"
+                },
+                "retryBackoff": {
+                    "description": "One of the RetryBackoff* constants",
+                    "type": "string"
+                },
+                "retryDelay": {
+                    "description": "Seconds before the first retry",
+                    "type": "integer"
+                },
+                "retryOn": {
+                    "description": "RetryOn* failures that are retried",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.CommandExecution": {
+            "type": "object",
+            "properties": {
+                "acknowledgedAt": {
+                    "type": "string"
+                },
+                "attempt": {
+                    "description": "Number of times the command was sent, retries included",
+                    "type": "integer"
+                },
+                "batchId": {
+                    "description": "Batch the execution was dispatched in, if any",
+                    "type": "string"
+                },
+                "commandConfigId": {
+                    "type": "string"
+                },
+                "commandExecutionTime": {
+                    "description": "Time the command was published to the device",
+                    "type": "string"
+                },
+                "completedAt": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deferredUntil": {
+                    "description": "Time a deferred execution fails if its device is still offline",
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deviceId": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Reason of the last failure or timeout",
+                    "type": "string"
+                },
+                "eventDriven": {
+                    "description": "Device responses advance the execution instead of a waiting worker",
+                    "type": "boolean"
+                },
+                "executionHistory": {
+                    "description": "Store execution events as JSON",
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "idempotencyKey": {
+                    "description": "Client supplied key deduplicating retried dispatch requests",
+                    "type": "string"
+                },
+                "issuedAt": {
+                    "type": "string"
+                },
+                "queue": {
+                    "description": "Asynq queue the task was enqueued on",
+                    "type": "string"
+                },
+                "responseDeadline": {
+                    "description": "Time an event-driven execution times out if the device has not responded",
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Last payload reported by the device",
+                    "type": "object"
+                },
+                "scheduledAt": {
+                    "description": "Time a scheduled execution is dispatched at",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "One of the ExecutionStatus* constants",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.CommandSchedule": {
+            "type": "object",
+            "properties": {
+                "commandConfigId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "cronSpec": {
+                    "description": "Standard 5-field cron expression or descriptor such as @daily",
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "deviceId": {
+                    "description": "Target device, unless the schedule targets a group or a tag selector",
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "groupId": {
+                    "description": "Target device group, resolved at every run",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastBatchId": {
+                    "description": "Batch dispatched by the last run to a group or tag selector",
+                    "type": "string"
+                },
+                "lastExecutionId": {
+                    "description": "Execution dispatched by the last run to a single device",
+                    "type": "string"
+                },
+                "lastRunAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nextRunAt": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "selector": {
+                    "description": "Target tag selector, resolved at every run",
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "IANA time zone the cron expression is evaluated in",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.Device": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "description": "Arbitrary attributes reported by the device",
+                    "type": "object"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "firmwareVersion": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Identifier the device uses in its MQTT topics",
+                    "type": "string"
+                },
+                "lastSeenAt": {
+                    "description": "Last time the device reported its status",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "One of the DeviceStatus* constants",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Key/value labels devices are targeted by, see DeviceGroup",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.DeviceGroup": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.Device"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.RolloutPolicy": {
+            "type": "object",
+            "properties": {
+                "canaryPercent": {
+                    "description": "Size of the first wave, 0 dispatches every device at once",
+                    "type": "integer"
+                },
+                "maxFailureRate": {
+                    "description": "Failed or timed out executions that pause the rollout right away, 0 disables it",
+                    "type": "integer"
+                },
+                "successThreshold": {
+                    "description": "Completed executions required to start the next wave",
+                    "type": "integer"
+                },
+                "wavePercent": {
+                    "description": "Size of the following waves, 0 dispatches the remaining devices at once",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CommandConfigCreateDTO": {
+            "type": "object",
             "required": [
                 "commandType",
                 "name"
@@ -289,23 +2072,71 @@ const docTemplate = `{
                 "acknowledgementTimeout": {
                     "type": "integer"
                 },
+                "allowConcurrent": {
+                    "type": "boolean"
+                },
                 "commandType": {
                     "type": "string"
                 },
                 "completionTimeout": {
                     "type": "integer"
                 },
+                "deferTtl": {
+                    "type": "integer",
+                    "minimum": 1
+                },
                 "description": {
                     "type": "string"
                 },
                 "isAcknowledgeRequired": {
                     "type": "boolean"
                 },
+                "isCompletionRequired": {
+                    "type": "boolean"
+                },
+                "maxRetries": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0
+                },
                 "name": {
                     "type": "string"
                 },
+                "offlinePolicy": {
+                    "type": "string",
+                    "enum": [
+                        "send",
+                        "reject",
+                        "defer"
+                    ]
+                },
                 "payloadSchema": {
                     "type": "string"
+                },
+                "priority": {
+                    "type": "string",
+                    "enum": [
+                        "high",
+                        "normal",
+                        "low"
+                    ]
+                },
+                "retryBackoff": {
+                    "type": "string",
+                    "enum": [
+                        "fixed",
+                        "exponential"
+                    ]
+                },
+                "retryDelay": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "retryOn": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
@@ -315,23 +2146,402 @@ const docTemplate = `{
                 "acknowledgementTimeout": {
                     "type": "integer"
                 },
+                "allowConcurrent": {
+                    "type": "boolean"
+                },
                 "commandType": {
                     "type": "string"
                 },
                 "completionTimeout": {
                     "type": "integer"
                 },
+                "deferTtl": {
+                    "type": "integer",
+                    "minimum": 1
+                },
                 "description": {
                     "type": "string"
                 },
                 "isAcknowledgeRequired": {
                     "type": "boolean"
                 },
+                "isCompletionRequired": {
+                    "type": "boolean"
+                },
+                "maxRetries": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0
+                },
                 "name": {
                     "type": "string"
                 },
+                "offlinePolicy": {
+                    "type": "string",
+                    "enum": [
+                        "send",
+                        "reject",
+                        "defer"
+                    ]
+                },
                 "payloadSchema": {
                     "type": "string"
+                },
+                "priority": {
+                    "type": "string",
+                    "enum": [
+                        "high",
+                        "normal",
+                        "low"
+                    ]
+                },
+                "retryBackoff": {
+                    "type": "string",
+                    "enum": [
+                        "fixed",
+                        "exponential"
+                    ]
+                },
+                "retryDelay": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "retryOn": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CommandExecuteDTO": {
+            "type": "object",
+            "required": [
+                "deviceIds"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "deviceId": {
+                    "type": "string"
+                },
+                "deviceIds": {
+                    "description": "Dispatch to several devices as a batch",
+                    "type": "array",
+                    "maxItems": 10000,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "executeAt": {
+                    "type": "string"
+                },
+                "executeIn": {
+                    "description": "Seconds",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "groupId": {
+                    "description": "Dispatch to the members of a device group as a batch",
+                    "type": "string"
+                },
+                "idempotencyKey": {
+                    "description": "Deduplicates retried requests, the Idempotency-Key header takes precedence",
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "priority": {
+                    "description": "Overrides the priority of the command config",
+                    "type": "string",
+                    "enum": [
+                        "high",
+                        "normal",
+                        "low"
+                    ]
+                },
+                "rollout": {
+                    "description": "Dispatch the batch in waves",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RolloutDTO"
+                        }
+                    ]
+                },
+                "selector": {
+                    "description": "Dispatch to the devices matching a tag selector as a batch, e.g. site=hanoi,model=x2",
+                    "type": "string",
+                    "maxLength": 1024
+                }
+            }
+        },
+        "models.DeviceCreateDTO": {
+            "type": "object",
+            "required": [
+                "id",
+                "tags"
+            ],
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Identifier the device uses in its MQTT topics",
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "name": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.DeviceGroupCreateDTO": {
+            "type": "object",
+            "required": [
+                "deviceIds",
+                "name"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "deviceIds": {
+                    "description": "Initial members",
+                    "type": "array",
+                    "maxItems": 10000,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "models.DeviceGroupMembersDTO": {
+            "type": "object",
+            "required": [
+                "deviceIds"
+            ],
+            "properties": {
+                "deviceIds": {
+                    "type": "array",
+                    "maxItems": 10000,
+                    "minItems": 1,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.DeviceGroupUpdateDTO": {
+            "type": "object",
+            "required": [
+                "deviceIds"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "deviceIds": {
+                    "description": "Replaces the members",
+                    "type": "array",
+                    "maxItems": 10000,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "models.DeviceUpdateDTO": {
+            "type": "object",
+            "required": [
+                "tags"
+            ],
+            "properties": {
+                "attributes": {
+                    "description": "Replaces the known attributes",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Replaces the tags",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.RescheduleExecutionDTO": {
+            "type": "object",
+            "properties": {
+                "executeAt": {
+                    "type": "string"
+                },
+                "executeIn": {
+                    "description": "Seconds",
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "models.RolloutDTO": {
+            "type": "object",
+            "required": [
+                "canaryPercent",
+                "successThreshold"
+            ],
+            "properties": {
+                "canaryPercent": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "maxFailureRate": {
+                    "description": "Pause as soon as this share of the wave failed",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "successThreshold": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "wavePercent": {
+                    "description": "Defaults to the remaining devices",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                }
+            }
+        },
+        "models.ScheduleCreateDTO": {
+            "type": "object",
+            "required": [
+                "commandConfigId",
+                "cronSpec",
+                "name"
+            ],
+            "properties": {
+                "commandConfigId": {
+                    "type": "string"
+                },
+                "cronSpec": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "deviceId": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "description": "Defaults to true",
+                    "type": "boolean"
+                },
+                "groupId": {
+                    "description": "Target the members of a device group",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "selector": {
+                    "description": "Target the devices matching a tag selector",
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "timezone": {
+                    "description": "Defaults to UTC",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ScheduleUpdateDTO": {
+            "type": "object",
+            "properties": {
+                "commandConfigId": {
+                    "type": "string"
+                },
+                "cronSpec": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "deviceId": {
+                    "description": "Setting a target replaces the current one",
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "groupId": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "selector": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "timezone": {
+                    "type": "string"
                 }
             }
         }
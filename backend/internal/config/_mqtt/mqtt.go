@@ -15,11 +15,32 @@ type MQTTConfig struct {
 	Password  string
 	CleanSess bool
 	StoreDir  string // "" or ":memory:" for in-memory
+	// StatusTopic is where the client announces its own presence: a retained "online" birth message
+	// on every (re)connect, and "offline" as Last Will when the connection drops. "" disables both.
+	StatusTopic string
+	// SharedGroup makes SubscribeShared use $share/<SharedGroup>/ subscriptions, so each message is delivered
+	// to only one of the clients of the group, e.g. the replicas of the backend. "" subscribes normally.
+	SharedGroup string
 }
 
+// Payloads of the presence messages published on MQTTConfig.StatusTopic.
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
 type MQTTClient struct {
-	client mqtt.Client
-	mu     sync.RWMutex
+	client      mqtt.Client
+	statusTopic string
+	sharedGroup string
+	mu          sync.RWMutex
+	// subscriptions are restored whenever the client (re)connects, since a clean session loses them
+	subscriptions map[string]subscription
+}
+
+type subscription struct {
+	qos     byte
+	handler mqtt.MessageHandler
 }
 
 var (
@@ -58,22 +79,53 @@ func getMQTTClient(cfg MQTTConfig) *MQTTClient {
 			}
 		})
 
+		m := &MQTTClient{statusTopic: cfg.StatusTopic, sharedGroup: cfg.SharedGroup, subscriptions: map[string]subscription{}}
+		opts.SetOnConnectHandler(m.onConnect)
+		// Announce presence so devices know when the backend is down
+		if cfg.StatusTopic != "" {
+			opts.SetWill(cfg.StatusTopic, PresenceOffline, 1, true)
+		}
+
 		// Set up store if specified
 		if cfg.StoreDir != "" && cfg.StoreDir != ":memory:" {
 			opts.SetStore(mqtt.NewFileStore(cfg.StoreDir))
 		}
 
-		client := mqtt.NewClient(opts)
-		if token := client.Connect(); token.Wait() && token.Error() != nil {
+		m.client = mqtt.NewClient(opts)
+		if token := m.client.Connect(); token.Wait() && token.Error() != nil {
 			log.Fatalf("MQTT connect error: %v", token.Error())
 		}
 
-		instance = &MQTTClient{client: client}
+		instance = m
 		log.Printf("MQTT client initialized: broker=%s, clientID=%s", cfg.Broker, cfg.ClientID)
 	})
 	return instance
 }
 
+// onConnect publishes the birth message and restores the subscriptions after every (re)connection.
+func (m *MQTTClient) onConnect(client mqtt.Client) {
+	if m.statusTopic != "" {
+		token := client.Publish(m.statusTopic, 1, true, PresenceOnline)
+		if token.Wait() && token.Error() != nil {
+			log.Errorf("Failed to publish birth message on %s: %v", m.statusTopic, token.Error())
+		}
+	}
+
+	m.mu.RLock()
+	subscriptions := make(map[string]subscription, len(m.subscriptions))
+	for topic, sub := range m.subscriptions {
+		subscriptions[topic] = sub
+	}
+	m.mu.RUnlock()
+
+	for topic, sub := range subscriptions {
+		token := client.Subscribe(topic, sub.qos, sub.handler)
+		if token.Wait() && token.Error() != nil {
+			log.Errorf("Failed to restore subscription to %s: %v", topic, token.Error())
+		}
+	}
+}
+
 // Publish sends a message to a topic.
 // qos: Quality of Service (0, 1, or 2)
 // retained: Whether the broker should retain this message for future subscribers
@@ -93,6 +145,7 @@ func (m *MQTTClient) Publish(topic string, qos byte, retained bool, payload any)
 }
 
 // Subscribe subscribes to a topic with a message handler.
+// The subscription is restored automatically when the client reconnects.
 //
 // Parameters:
 //
@@ -121,11 +174,40 @@ func (m *MQTTClient) Subscribe(topic string, handler mqtt.MessageHandler, qos ..
 		return token.Error()
 	}
 
+	m.mu.Lock()
+	m.subscriptions[topic] = subscription{qos: qosLevel, handler: handler}
+	m.mu.Unlock()
 	return nil
 }
 
+// SubscribeShared subscribes to a topic as a member of the shared subscription group of the client,
+// so each message is handled by a single client of the group. Without a group it is a regular subscription.
+// Handlers receive the messages on their original topic, without the $share prefix.
+func (m *MQTTClient) SubscribeShared(topic string, handler mqtt.MessageHandler, qos ...byte) error {
+	return m.Subscribe(m.SharedTopic(topic), handler, qos...)
+}
+
+// SharedTopic returns the topic filter SubscribeShared subscribes to for the topic.
+func (m *MQTTClient) SharedTopic(topic string) string {
+	if m.sharedGroup == "" {
+		return topic
+	}
+	return "$share/" + m.sharedGroup + "/" + topic
+}
+
+// IsShared reports whether SubscribeShared subscriptions are shared with other clients.
+func (m *MQTTClient) IsShared() bool {
+	return m.sharedGroup != ""
+}
+
 // Unsubscribe removes subscription from one or more topics.
 func (m *MQTTClient) Unsubscribe(topics ...string) error {
+	m.mu.Lock()
+	for _, topic := range topics {
+		delete(m.subscriptions, topic)
+	}
+	m.mu.Unlock()
+
 	if m.client == nil || !m.client.IsConnected() {
 		return fmt.Errorf("MQTT client is not connected")
 	}
@@ -149,9 +231,15 @@ func (m *MQTTClient) IsConnected() bool {
 }
 
 // Disconnect cleanly disconnects the client.
+// The broker does not send the Last Will on a clean disconnect, so the offline status is published first.
 // quiesce: milliseconds to wait for pending messages to complete
 func (m *MQTTClient) Disconnect(quiesce uint) {
 	if m.client != nil {
+		if m.statusTopic != "" && m.client.IsConnected() {
+			if err := m.Publish(m.statusTopic, 1, true, PresenceOffline); err != nil {
+				log.Warnf("Failed to publish offline status on %s: %v", m.statusTopic, err)
+			}
+		}
 		m.client.Disconnect(quiesce)
 		log.Println("MQTT client disconnected")
 	}
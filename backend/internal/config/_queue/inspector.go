@@ -0,0 +1,37 @@
+package _queue
+
+import (
+	"sync"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	queueInspector     *asynq.Inspector
+	queueInspectorOnce sync.Once
+)
+
+func InitQueueInspector(cfg asynq.RedisClientOpt) {
+	queueInspectorOnce.Do(func() {
+		queueInspector = asynq.NewInspector(cfg)
+		log.Infof("Asynq Redis queue inspector initialized at: %s", cfg.Addr)
+	})
+}
+
+func GetQueueInspector() *asynq.Inspector {
+	if queueInspector == nil {
+		log.Fatalf("Queue inspector has not been initialized. Call InitQueueInspector first.")
+	}
+	return queueInspector
+}
+
+func CloseQueueInspector() {
+	if queueInspector != nil {
+		if err := queueInspector.Close(); err != nil {
+			log.Errorf("Failed to close queue inspector: %v", err)
+		} else {
+			log.Info("Queue inspector disconnected.")
+		}
+	}
+}
@@ -2,27 +2,56 @@ package _queue
 
 import (
 	"context"
+	"os"
+	"strconv"
 
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
 )
+
+// Queues tasks are dispatched on, from the highest to the lowest priority.
+const (
+	QueueCritical = "critical"
+	QueueDefault  = "default"
+	QueueLow      = "low"
+)
+
+// RedisOpt is the Redis connection shared by the queue client, server and schedulers.
+var RedisOpt asynq.RedisClientOpt
+
 type noopLogger struct{}
+
 func (noopLogger) Printf(ctx context.Context, format string, v ...any) {}
 
 func Init() {
 	redisAddress := "redis:6379"
-	redis.SetLogger(noopLogger{})	// Initialize Queue Client and Server
-	InitQueueClient(asynq.RedisClientOpt{Addr: redisAddress})
+	RedisOpt = asynq.RedisClientOpt{Addr: redisAddress}
+	redis.SetLogger(noopLogger{}) // Initialize Queue Client and Server
+	InitQueueClient(RedisOpt)
+	InitQueueInspector(RedisOpt)
+	InitRedisClient(RedisOpt)
 
-	InitQueueServer(asynq.RedisClientOpt{Addr: redisAddress},
+	InitQueueServer(RedisOpt,
 		asynq.Config{
 			// Specify how many concurrent workers to use
 			Concurrency: 10,
 			// Optionally specify multiple queues with different priority.
 			Queues: map[string]int{
-				"critical": 6,
-				"default":  3,
-				"low":      1,
+				QueueCritical: 6,
+				QueueDefault:  3,
+				QueueLow:      1,
 			},
+			// In strict mode a queue is only processed once the higher priority ones are empty,
+			// so emergency commands (e.g. emergency stop) never wait behind routine ones
+			StrictPriority: strictPriority(),
+			// Let each task type decide how long to wait before being retried
+			RetryDelayFunc: retryDelay,
+			IsFailure:      isFailure,
 		})
 }
+
+// strictPriority reads the QUEUE_STRICT_PRIORITY environment variable, disabled by default.
+func strictPriority() bool {
+	strict, err := strconv.ParseBool(os.Getenv("QUEUE_STRICT_PRIORITY"))
+	return err == nil && strict
+}
@@ -0,0 +1,39 @@
+package _queue
+
+import (
+	"sync"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	redisClient     redis.UniversalClient
+	redisClientOnce sync.Once
+)
+
+// InitRedisClient creates a Redis client on the queue's Redis, for coordination the queue itself does not provide.
+func InitRedisClient(cfg asynq.RedisClientOpt) {
+	redisClientOnce.Do(func() {
+		redisClient = cfg.MakeRedisClient().(redis.UniversalClient)
+		log.Infof("Redis client initialized at: %s", cfg.Addr)
+	})
+}
+
+func GetRedisClient() redis.UniversalClient {
+	if redisClient == nil {
+		log.Fatalf("Redis client has not been initialized. Call InitRedisClient first.")
+	}
+	return redisClient
+}
+
+func CloseRedisClient() {
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			log.Errorf("Failed to close redis client: %v", err)
+		} else {
+			log.Info("Redis client disconnected.")
+		}
+	}
+}
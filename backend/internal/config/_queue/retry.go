@@ -0,0 +1,56 @@
+package _queue
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+var (
+	retryDelayFuncs   = map[string]asynq.RetryDelayFunc{}
+	retryDelayFuncsMu sync.RWMutex
+
+	notFailureErrs   []error
+	notFailureErrsMu sync.RWMutex
+)
+
+// RegisterRetryDelayFunc sets the function computing the delay before retrying tasks of the given type.
+// Task types without a registered function use asynq's default exponential backoff.
+func RegisterRetryDelayFunc(taskType string, fn asynq.RetryDelayFunc) {
+	retryDelayFuncsMu.Lock()
+	defer retryDelayFuncsMu.Unlock()
+	retryDelayFuncs[taskType] = fn
+}
+
+// retryDelay dispatches to the retry delay function registered for the task type.
+func retryDelay(n int, err error, t *asynq.Task) time.Duration {
+	retryDelayFuncsMu.RLock()
+	fn, ok := retryDelayFuncs[t.Type()]
+	retryDelayFuncsMu.RUnlock()
+	if !ok {
+		return asynq.DefaultRetryDelayFunc(n, err, t)
+	}
+	return fn(n, err, t)
+}
+
+// RegisterNotFailure marks errors that retry the task without counting as a failed attempt,
+// e.g. when a task has to wait for a resource held by another task.
+func RegisterNotFailure(err error) {
+	notFailureErrsMu.Lock()
+	defer notFailureErrsMu.Unlock()
+	notFailureErrs = append(notFailureErrs, err)
+}
+
+// isFailure reports whether the error returned by a task counts towards its retries.
+func isFailure(err error) bool {
+	notFailureErrsMu.RLock()
+	defer notFailureErrsMu.RUnlock()
+	for _, notFailure := range notFailureErrs {
+		if errors.Is(err, notFailure) {
+			return false
+		}
+	}
+	return err != nil
+}
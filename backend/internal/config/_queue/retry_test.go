@@ -0,0 +1,31 @@
+package _queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFailure(t *testing.T) {
+	errBusy := errors.New("resource is busy")
+	RegisterNotFailure(errBusy)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "No error", err: nil, expected: false},
+		{name: "Failure", err: errors.New("timed out"), expected: true},
+		{name: "Registered error", err: errBusy, expected: false},
+		{name: "Wrapped registered error", err: fmt.Errorf("%w: task t1", errBusy), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isFailure(tt.err))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package _queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	periodicTaskManager     *asynq.PeriodicTaskManager
+	periodicTaskManagerOnce sync.Once
+)
+
+// InitPeriodicTaskManager creates the manager enqueuing the periodic tasks returned by the provider.
+// The provider is polled every syncInterval so changes to the periodic tasks are picked up while running.
+func InitPeriodicTaskManager(cfg asynq.RedisClientOpt, provider asynq.PeriodicTaskConfigProvider, syncInterval time.Duration) {
+	periodicTaskManagerOnce.Do(func() {
+		manager, err := asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+			RedisConnOpt:               cfg,
+			PeriodicTaskConfigProvider: provider,
+			SyncInterval:               syncInterval,
+			SchedulerOpts:              &asynq.SchedulerOpts{Location: time.UTC},
+		})
+		if err != nil {
+			log.Fatalf("Could not create periodic task manager: %v", err)
+		}
+		periodicTaskManager = manager
+		log.Infof("Asynq periodic task manager initialized at: %s", cfg.Addr)
+	})
+}
+
+func GetPeriodicTaskManager() *asynq.PeriodicTaskManager {
+	if periodicTaskManager == nil {
+		log.Fatalf("Periodic task manager has not been initialized. Call InitPeriodicTaskManager first.")
+	}
+	return periodicTaskManager
+}
+
+func ClosePeriodicTaskManager() {
+	if periodicTaskManager != nil {
+		periodicTaskManager.Shutdown()
+		log.Info("Periodic task manager stopped.")
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"command-dispatcher/internal/config/_queue"
 	"command-dispatcher/internal/config/db"
 	"command-dispatcher/internal/config/log"
+	"command-dispatcher/internal/config/replica"
 	"crypto/rand"
 	"fmt"
+	"os"
 )
 
 var mqttCfg = _mqtt.MQTTConfig{
@@ -16,6 +18,11 @@ var mqttCfg = _mqtt.MQTTConfig{
 	Password:  "",
 	CleanSess: true,
 	StoreDir:  ":memory:",
+	// Devices watch dispatcher/+/status to know which replicas of the dispatcher are up. Each replica has its
+	// own topic, so the Last Will of a replica does not override the birth message of the others.
+	StatusTopic: "dispatcher/" + replica.ID + "/status",
+	// Replicas sharing a group split the device messages between them instead of all receiving each one
+	SharedGroup: os.Getenv("MQTT_SHARED_GROUP"),
 }
 
 func Init() {
@@ -11,12 +11,13 @@ func Init() {
 	dsn := "host=postgres user=postgres password=postgres dbname=postgres port=5432 sslmode=disable TimeZone=Asia/Shanghai"
 
 	var err error
-	Handler, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps driver errors to gorm errors such as gorm.ErrDuplicatedKey
+	Handler, err = gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic("failed to connect database")
 	}
 
-	err = Handler.AutoMigrate(&CommandConfig{}, &CommandExecution{})
+	err = Handler.AutoMigrate(&CommandConfig{}, &CommandExecution{}, &CommandSchedule{}, &CommandBatch{}, &Device{}, &DeviceGroup{})
 
 	if err != nil {
 		return
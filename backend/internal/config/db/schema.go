@@ -1,10 +1,14 @@
 package db
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
@@ -25,24 +29,295 @@ func (b *Base) BeforeCreate(tx *gorm.DB) (err error) {
 // CommandConfig defines the configuration for a specific command.
 type CommandConfig struct {
 	Base
-	Name                  string `json:"name" gorm:"unique;not null"`
-	Description           string `json:"description"`
-	CommandType           string `json:"commandType" gorm:"not null"` // e.g., "rpc", "deviceData", "configuration"
-	IsAcknowledgeRequired bool   `json:"isAcknowledgeRequired" gorm:"default:false"`
-	PayloadSchema         string `json:"payloadSchema" gorm:"default:'{}'"` // JSON schema for validating command arguments/payload
-	AcknowlegmentTimeout  int    `json:"acknowledgementTimeout" gorm:"default:60"`
-	CompletionTimeout     int    `json:"completionTimeout" gorm:"default:60"`
+	Name                  string     `json:"name" gorm:"unique;not null"`
+	Description           string     `json:"description"`
+	CommandType           string     `json:"commandType" gorm:"not null"` // e.g., "rpc", "deviceData", "configuration"
+	IsAcknowledgeRequired bool       `json:"isAcknowledgeRequired" gorm:"default:false"`
+	IsCompletionRequired  *bool      `json:"isCompletionRequired" gorm:"default:true"` // Pointer so an explicit false is not replaced by the column default
+	PayloadSchema         string     `json:"payloadSchema" gorm:"default:'{}'"`        // JSON schema for validating command arguments/payload
+	AcknowlegmentTimeout  int        `json:"acknowledgementTimeout" gorm:"default:60"`
+	CompletionTimeout     int        `json:"completionTimeout" gorm:"default:60"`
+	MaxRetries            int        `json:"maxRetries" gorm:"default:0"`
+	RetryBackoff          string     `json:"retryBackoff" gorm:"default:'fixed'"`                               // One of the RetryBackoff* constants
+	RetryDelay            int        `json:"retryDelay" gorm:"default:10"`                                      // Seconds before the first retry
+	RetryOn               StringList `json:"retryOn" gorm:"type:jsonb;default:'[]'" swaggertype:"array,string"` // RetryOn* failures that are retried
+	Priority              string     `json:"priority" gorm:"default:'normal'"`                                  // One of the Priority* constants
+	AllowConcurrent       bool       `json:"allowConcurrent" gorm:"default:false"`                              // Opt out of running one command at a time per device
+	OfflinePolicy         string     `json:"offlinePolicy" gorm:"default:'send'"`                               // One of the OfflinePolicy* constants
+	DeferTTL              int        `json:"deferTtl" gorm:"default:3600"`                                      // Seconds a deferred command waits for its device to come online
 }
 
+// Policies for commands dispatched to a device known to be offline.
+const (
+	OfflinePolicySend   = "send"   // Dispatch anyway
+	OfflinePolicyReject = "reject" // Refuse the dispatch
+	OfflinePolicyDefer  = "defer"  // Hold the execution until the device comes online, or fail it after DeferTTL
+)
+
+// Priorities of a command, each dispatched on its own queue.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// Backoff strategies between retries of a command.
+const (
+	RetryBackoffFixed       = "fixed"
+	RetryBackoffExponential = "exponential" // Doubles the delay on every retry, with jitter
+)
+
+// Device failures a command can be retried on, listed in CommandConfig.RetryOn.
+// Other errors (e.g. the broker being unavailable) are always retried up to MaxRetries.
+const (
+	RetryOnAcknowledgementTimeout = "acknowledgementTimeout"
+	RetryOnCompletionTimeout      = "completionTimeout"
+	RetryOnDeviceFailure          = "deviceFailure"
+)
+
+// StringList is a list of strings stored as a JSON array.
+type StringList []string
+
+// Value implements driver.Valuer.
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (l *StringList) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", value)
+	}
+}
+
+// Statuses a CommandExecution moves through during its lifecycle.
+const (
+	ExecutionStatusScheduled    = "SCHEDULED" // Waiting in the queue until its scheduled time
+	ExecutionStatusPending      = "PENDING"
+	ExecutionStatusQueuedBehind = "QUEUED_BEHIND" // Waiting for another command of the same device to finish
+	ExecutionStatusDeferred     = "DEFERRED"      // Waiting for the device to come online
+	ExecutionStatusSent         = "SENT"
+	ExecutionStatusAcknowledged = "ACKNOWLEDGED"
+	ExecutionStatusCompleted    = "COMPLETED"
+	ExecutionStatusTimedOut     = "TIMED_OUT"
+	ExecutionStatusFailed       = "FAILED"
+	ExecutionStatusRetrying     = "RETRYING" // Failed or timed out, and will be retried
+	ExecutionStatusCancelled    = "CANCELLED"
+	ExecutionStatusRequeued     = "REQUEUED"    // Processing was interrupted before the outcome was known, and will be retried
+	ExecutionStatusInterrupted  = "INTERRUPTED" // Processing was interrupted before the outcome was known, with no retries left
+)
+
 // CommandExecution records the history and status of a command sent to a device.
 type CommandExecution struct {
 	Base
 	DeviceID             string          `json:"deviceId" gorm:"not null;index"`
 	CommandConfigID      string          `json:"commandConfigId" gorm:"type:uuid;not null"`
 	CommandConfig        CommandConfig   `json:"-" gorm:"foreignKey:CommandConfigID"` // Belongs-to relationship
-	Status               string          `json:"status" gorm:"index"`                 // e.g., "PENDING", "SENT", "ACKNOWLEDGED", "COMPLETED", "FAILED"
+	Status               string          `json:"status" gorm:"index"`                 // One of the ExecutionStatus* constants
+	Queue                string          `json:"queue"`                               // Asynq queue the task was enqueued on
 	IssuedAt             time.Time       `json:"issuedAt" gorm:"autoCreateTime"`
+	ScheduledAt          *time.Time      `json:"scheduledAt"` // Time a scheduled execution is dispatched at
+	AcknowledgedAt       *time.Time      `json:"acknowledgedAt"`
 	CompletedAt          *time.Time      `json:"completedAt"`
-	ExecutionHistory     json.RawMessage `json:"executionHistory" gorm:"type:jsonb"` // Store execution events as JSON
-	CommandExecutionTime time.Time       `json:"commandExecutionTime"`
+	ExecutionHistory     json.RawMessage `json:"executionHistory" gorm:"type:jsonb" swaggertype:"array,object"` // Store execution events as JSON
+	CommandExecutionTime time.Time       `json:"commandExecutionTime"`                                          // Time the command was published to the device
+	Attempt              int             `json:"attempt"`                                                       // Number of times the command was sent, retries included
+	Result               json.RawMessage `json:"result" gorm:"type:jsonb" swaggertype:"object"`                 // Last payload reported by the device
+	Error                string          `json:"error,omitempty"`                                               // Reason of the last failure or timeout
+	IdempotencyKey       *string         `json:"idempotencyKey,omitempty" gorm:"uniqueIndex"`                   // Client supplied key deduplicating retried dispatch requests
+	BatchID              *string         `json:"batchId,omitempty" gorm:"type:uuid;index"`                      // Batch the execution was dispatched in, if any
+	DeferredUntil        *time.Time      `json:"deferredUntil,omitempty"`                                       // Time a deferred execution fails if its device is still offline
+	EventDriven          bool            `json:"eventDriven"`                                                   // Device responses advance the execution instead of a waiting worker
+	ResponseDeadline     *time.Time      `json:"responseDeadline,omitempty" gorm:"index"`                       // Time an event-driven execution times out if the device has not responded
+	Command              json.RawMessage `json:"-" gorm:"type:jsonb"`                                           // Command the execution was dispatched with, sent again on retries
+}
+
+// CompletionRequired reports whether the worker must wait for the device to report completion.
+func (c *CommandConfig) CompletionRequired() bool {
+	return c.IsCompletionRequired == nil || *c.IsCompletionRequired
+}
+
+// IsFinished reports whether the execution reached a final status and will not change anymore.
+func (e *CommandExecution) IsFinished() bool {
+	return IsFinalExecutionStatus(e.Status)
+}
+
+// AwaitsResponse reports whether the execution is event-driven and waits for the device to respond.
+func (e *CommandExecution) AwaitsResponse() bool {
+	return e.EventDriven && slices.Contains(AwaitingExecutionStatuses, e.Status)
+}
+
+// AwaitingExecutionStatuses are the statuses of an execution waiting for the device to respond.
+var AwaitingExecutionStatuses = []string{ExecutionStatusSent, ExecutionStatusAcknowledged}
+
+// FinalExecutionStatuses are the statuses an execution never leaves.
+var FinalExecutionStatuses = []string{
+	ExecutionStatusCompleted, ExecutionStatusFailed, ExecutionStatusTimedOut, ExecutionStatusCancelled, ExecutionStatusInterrupted,
+}
+
+// IsFinalExecutionStatus reports whether an execution in the given status will not change anymore.
+func IsFinalExecutionStatus(status string) bool {
+	return slices.Contains(FinalExecutionStatuses, status)
+}
+
+// ExecutionEvent is a single status transition stored in CommandExecution.ExecutionHistory.
+type ExecutionEvent struct {
+	Status    string          `json:"status"`
+	Message   string          `json:"message,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"` // Payload sent by the device, if any
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Statuses of a CommandBatch.
+const (
+	BatchStatusRunning   = "RUNNING"
+	BatchStatusPaused    = "PAUSED"   // Rollout halted after a wave did not meet its thresholds, waiting to be resumed or aborted
+	BatchStatusFinished  = "FINISHED" // Every execution of the batch reached a final status
+	BatchStatusAborted   = "ABORTED"  // Rollout stopped, remaining devices are never dispatched
+	BatchStatusCancelled = "CANCELLED"
+)
+
+// RolloutPolicy dispatches a batch in waves, each wave starting once the previous one succeeded.
+// Percentages are of the devices of the batch for the wave sizes, and of the devices of the wave for the thresholds.
+type RolloutPolicy struct {
+	CanaryPercent    int `json:"canaryPercent,omitempty"`    // Size of the first wave, 0 dispatches every device at once
+	WavePercent      int `json:"wavePercent,omitempty"`      // Size of the following waves, 0 dispatches the remaining devices at once
+	SuccessThreshold int `json:"successThreshold,omitempty"` // Completed executions required to start the next wave
+	MaxFailureRate   int `json:"maxFailureRate,omitempty"`   // Failed or timed out executions that pause the rollout right away, 0 disables it
+}
+
+// Enabled reports whether the batch is dispatched in waves.
+func (p RolloutPolicy) Enabled() bool {
+	return p.CanaryPercent > 0
+}
+
+// CommandBatch groups the executions of a command dispatched to several devices in one request.
+type CommandBatch struct {
+	Base
+	Description     string           `json:"description"`
+	CommandConfigID string           `json:"commandConfigId" gorm:"type:uuid;not null;index"`
+	DeviceIDs       StringList       `json:"deviceIds" gorm:"type:jsonb" swaggertype:"array,string"` // Target devices, resolved when the batch was dispatched
+	GroupID         *string          `json:"groupId,omitempty" gorm:"type:uuid"`                     // Device group the devices were resolved from, if any
+	Selector        string           `json:"selector,omitempty"`                                     // Tag selector the devices were resolved from, if any
+	Status          string           `json:"status" gorm:"index"`                                    // One of the BatchStatus* constants
+	IdempotencyKey  *string          `json:"idempotencyKey,omitempty" gorm:"uniqueIndex"`
+	Rollout         RolloutPolicy    `json:"rollout" gorm:"embedded;embeddedPrefix:rollout_"`
+	Wave            int              `json:"wave"`                  // Current wave, starting at 1
+	WaveStart       int              `json:"waveStart"`             // Index in DeviceIDs of the first device of the current wave
+	Dispatched      int              `json:"dispatched"`            // Number of devices dispatched so far, in DeviceIDs order
+	PauseReason     string           `json:"pauseReason,omitempty"` // Why the rollout paused
+	Command         json.RawMessage  `json:"-" gorm:"type:jsonb"`   // Command dispatched to every device, kept for the following waves
+	Progress        map[string]int64 `json:"progress" gorm:"-"`     // Number of executions per status
+}
+
+// SameTarget reports whether both batches target the same devices.
+// Batches resolved from a group or a tag selector target the same devices if they were resolved from the same one,
+// even though the membership may have changed in between.
+func (b *CommandBatch) SameTarget(other *CommandBatch) bool {
+	if b.GroupID != nil || other.GroupID != nil || b.Selector != "" || other.Selector != "" {
+		return ptrEqual(b.GroupID, other.GroupID) && b.Selector == other.Selector
+	}
+	return slices.Equal(b.DeviceIDs, other.DeviceIDs)
+}
+
+func ptrEqual[T comparable](a, b *T) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
+
+// WaveDeviceIDs returns the devices of the current wave.
+func (b *CommandBatch) WaveDeviceIDs() []string {
+	return b.DeviceIDs[b.WaveStart:b.Dispatched]
+}
+
+// NextWaveSize returns the number of devices of the next wave.
+func (b *CommandBatch) NextWaveSize() int {
+	remaining := len(b.DeviceIDs) - b.Dispatched
+	percent := b.Rollout.WavePercent
+	if b.Wave == 0 {
+		percent = b.Rollout.CanaryPercent
+	}
+	if percent <= 0 {
+		return remaining
+	}
+	size := (len(b.DeviceIDs)*percent + 99) / 100 // Round up so every wave has at least one device
+	return min(max(size, 1), remaining)
+}
+
+// CommandSchedule dispatches a command configuration to a device on a recurring cron schedule.
+type CommandSchedule struct {
+	Base
+	Name            string              `json:"name" gorm:"unique;not null"`
+	Description     string              `json:"description"`
+	CommandConfigID string              `json:"commandConfigId" gorm:"type:uuid;not null;index"`
+	CommandConfig   CommandConfig       `json:"-" gorm:"foreignKey:CommandConfigID"` // Belongs-to relationship
+	CronSpec        string              `json:"cronSpec" gorm:"not null"`            // Standard 5-field cron expression or descriptor such as @daily
+	Timezone        string              `json:"timezone" gorm:"default:'UTC'"`       // IANA time zone the cron expression is evaluated in
+	DeviceID        string              `json:"deviceId,omitempty"`                  // Target device, unless the schedule targets a group or a tag selector
+	GroupID         *string             `json:"groupId,omitempty" gorm:"type:uuid"`  // Target device group, resolved at every run
+	Selector        string              `json:"selector,omitempty"`                  // Target tag selector, resolved at every run
+	Parameters      []map[string]string `json:"parameters" gorm:"type:jsonb;serializer:json"`
+	Enabled         bool                `json:"enabled" gorm:"not null"`
+	LastRunAt       *time.Time          `json:"lastRunAt"`
+	NextRunAt       *time.Time          `json:"nextRunAt"`
+	LastExecutionID string              `json:"lastExecutionId,omitempty"` // Execution dispatched by the last run to a single device
+	LastBatchID     string              `json:"lastBatchId,omitempty"`     // Batch dispatched by the last run to a group or tag selector
+}
+
+// Cronspec returns the cron expression prefixed with the time zone of the schedule.
+func (s *CommandSchedule) Cronspec() string {
+	if s.Timezone == "" {
+		return s.CronSpec
+	}
+	return "CRON_TZ=" + s.Timezone + " " + s.CronSpec
+}
+
+// NextRun returns the first time the schedule fires after the given time.
+func (s *CommandSchedule) NextRun(after time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(s.Cronspec())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
+
+// Presence statuses of a Device.
+const (
+	DeviceStatusUnknown = "UNKNOWN" // Registered but never reported its status
+	DeviceStatusOnline  = "ONLINE"
+	DeviceStatusOffline = "OFFLINE"
+)
+
+// Device is a device known to the dispatcher, registered through the API or by reporting its status.
+type Device struct {
+	ID              string            `json:"id" gorm:"primaryKey"` // Identifier the device uses in its MQTT topics
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Status          string            `json:"status" gorm:"default:'UNKNOWN';index"` // One of the DeviceStatus* constants
+	LastSeenAt      *time.Time        `json:"lastSeenAt"`                            // Last time the device reported its status
+	FirmwareVersion string            `json:"firmwareVersion"`
+	Attributes      json.RawMessage   `json:"attributes" gorm:"type:jsonb;default:'{}'" swaggertype:"object"`                      // Arbitrary attributes reported by the device
+	Tags            map[string]string `json:"tags" gorm:"type:jsonb;serializer:json;default:'{}';index:idx_devices_tags,type:gin"` // Key/value labels devices are targeted by, see DeviceGroup
+	CreatedAt       time.Time         `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time         `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// DeviceGroup is a named set of devices commands can be dispatched to at once.
+// Membership is static; devices can also be targeted dynamically with a tag selector.
+type DeviceGroup struct {
+	Base
+	Name        string   `json:"name" gorm:"unique;not null"`
+	Description string   `json:"description"`
+	Devices     []Device `json:"devices,omitempty" gorm:"many2many:device_group_members;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextWaveSize(t *testing.T) {
+	devices := func(n int) StringList {
+		ids := make(StringList, n)
+		for i := range ids {
+			ids[i] = "device"
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		batch    CommandBatch
+		expected int
+	}{
+		{
+			name:     "Canary wave",
+			batch:    CommandBatch{DeviceIDs: devices(100), Rollout: RolloutPolicy{CanaryPercent: 5, WavePercent: 25}},
+			expected: 5,
+		},
+		{
+			name:     "Following wave",
+			batch:    CommandBatch{DeviceIDs: devices(100), Rollout: RolloutPolicy{CanaryPercent: 5, WavePercent: 25}, Wave: 1, Dispatched: 5},
+			expected: 25,
+		},
+		{
+			name:     "Rounded up",
+			batch:    CommandBatch{DeviceIDs: devices(10), Rollout: RolloutPolicy{CanaryPercent: 15}},
+			expected: 2,
+		},
+		{
+			name:     "At least one device",
+			batch:    CommandBatch{DeviceIDs: devices(3), Rollout: RolloutPolicy{CanaryPercent: 1}},
+			expected: 1,
+		},
+		{
+			name:     "Bounded by the remaining devices",
+			batch:    CommandBatch{DeviceIDs: devices(100), Rollout: RolloutPolicy{CanaryPercent: 5, WavePercent: 25}, Wave: 4, Dispatched: 80},
+			expected: 20,
+		},
+		{
+			name:     "No wave size dispatches the remaining devices",
+			batch:    CommandBatch{DeviceIDs: devices(100), Rollout: RolloutPolicy{CanaryPercent: 5}, Wave: 1, Dispatched: 5},
+			expected: 95,
+		},
+		{
+			name:     "Every device dispatched",
+			batch:    CommandBatch{DeviceIDs: devices(10), Rollout: RolloutPolicy{CanaryPercent: 50, WavePercent: 50}, Wave: 2, Dispatched: 10},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.batch.NextWaveSize())
+		})
+	}
+}
+
+func TestIsFinalExecutionStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected bool
+	}{
+		{status: ExecutionStatusScheduled},
+		{status: ExecutionStatusPending},
+		{status: ExecutionStatusSent},
+		{status: ExecutionStatusAcknowledged},
+		{status: ExecutionStatusRetrying},
+		{status: ExecutionStatusRequeued},
+		{status: ExecutionStatusCompleted, expected: true},
+		{status: ExecutionStatusFailed, expected: true},
+		{status: ExecutionStatusTimedOut, expected: true},
+		{status: ExecutionStatusCancelled, expected: true},
+		{status: ExecutionStatusInterrupted, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsFinalExecutionStatus(tt.status))
+			execution := CommandExecution{Status: tt.status}
+			assert.Equal(t, tt.expected, execution.IsFinished())
+		})
+	}
+}
+
+func TestCompletionRequired(t *testing.T) {
+	required, notRequired := true, false
+
+	tests := []struct {
+		name     string
+		config   CommandConfig
+		expected bool
+	}{
+		{name: "Unset", config: CommandConfig{}, expected: true},
+		{name: "Required", config: CommandConfig{IsCompletionRequired: &required}, expected: true},
+		{name: "Not required", config: CommandConfig{IsCompletionRequired: &notRequired}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.config.CompletionRequired())
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	after := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		schedule  CommandSchedule
+		expected  time.Time
+		expectErr bool
+	}{
+		{
+			name:     "Cron expression",
+			schedule: CommandSchedule{CronSpec: "0 * * * *"},
+			expected: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Descriptor",
+			schedule: CommandSchedule{CronSpec: "@daily", Timezone: "UTC"},
+			expected: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Evaluated in the time zone of the schedule",
+			schedule: CommandSchedule{CronSpec: "0 2 * * *", Timezone: "Asia/Ho_Chi_Minh"},
+			expected: time.Date(2024, 5, 1, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "Invalid expression",
+			schedule:  CommandSchedule{CronSpec: "every day"},
+			expectErr: true,
+		},
+		{
+			name:      "Unknown time zone",
+			schedule:  CommandSchedule{CronSpec: "@daily", Timezone: "Mars/Olympus"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := tt.schedule.NextRun(after)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.expected.Equal(next), "expected %s, got %s", tt.expected, next)
+		})
+	}
+}
+
+func TestWaveDeviceIDs(t *testing.T) {
+	batch := CommandBatch{DeviceIDs: StringList{"d1", "d2", "d3", "d4", "d5"}, Rollout: RolloutPolicy{CanaryPercent: 20, WavePercent: 40}}
+	assert.True(t, batch.Rollout.Enabled())
+
+	batch.Wave, batch.WaveStart, batch.Dispatched = 1, 0, 1
+	assert.Equal(t, []string{"d1"}, batch.WaveDeviceIDs())
+
+	batch.Wave, batch.WaveStart, batch.Dispatched = 2, 1, 3
+	assert.Equal(t, []string{"d2", "d3"}, batch.WaveDeviceIDs())
+
+	// Without a canary every device is dispatched in a single wave
+	assert.False(t, RolloutPolicy{WavePercent: 40}.Enabled())
+}
+
+func TestSameTarget(t *testing.T) {
+	group, otherGroup := "g1", "g2"
+
+	tests := []struct {
+		name     string
+		a, b     CommandBatch
+		expected bool
+	}{
+		{
+			name:     "Same devices",
+			a:        CommandBatch{DeviceIDs: StringList{"d1", "d2"}},
+			b:        CommandBatch{DeviceIDs: StringList{"d1", "d2"}},
+			expected: true,
+		},
+		{
+			name: "Other devices",
+			a:    CommandBatch{DeviceIDs: StringList{"d1", "d2"}},
+			b:    CommandBatch{DeviceIDs: StringList{"d1", "d3"}},
+		},
+		{
+			name:     "Same group with other members",
+			a:        CommandBatch{GroupID: &group, DeviceIDs: StringList{"d1"}},
+			b:        CommandBatch{GroupID: &group, DeviceIDs: StringList{"d1", "d2"}},
+			expected: true,
+		},
+		{
+			name: "Other group",
+			a:    CommandBatch{GroupID: &group},
+			b:    CommandBatch{GroupID: &otherGroup},
+		},
+		{
+			name:     "Same selector",
+			a:        CommandBatch{Selector: "site=hanoi", DeviceIDs: StringList{"d1"}},
+			b:        CommandBatch{Selector: "site=hanoi", DeviceIDs: StringList{"d2"}},
+			expected: true,
+		},
+		{
+			name: "Group and selector",
+			a:    CommandBatch{GroupID: &group},
+			b:    CommandBatch{Selector: "site=hanoi"},
+		},
+		{
+			name: "Devices and selector resolving to them",
+			a:    CommandBatch{DeviceIDs: StringList{"d1"}},
+			b:    CommandBatch{Selector: "site=hanoi", DeviceIDs: StringList{"d1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.a.SameTarget(&tt.b))
+			assert.Equal(t, tt.expected, tt.b.SameTarget(&tt.a))
+		})
+	}
+}
+
+func TestAwaitsResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		execution CommandExecution
+		expected  bool
+	}{
+		{name: "Sent", execution: CommandExecution{EventDriven: true, Status: ExecutionStatusSent}, expected: true},
+		{name: "Acknowledged", execution: CommandExecution{EventDriven: true, Status: ExecutionStatusAcknowledged}, expected: true},
+		{name: "Completed", execution: CommandExecution{EventDriven: true, Status: ExecutionStatusCompleted}},
+		{name: "Retrying", execution: CommandExecution{EventDriven: true, Status: ExecutionStatusRetrying}},
+		{name: "Waited on by a worker", execution: CommandExecution{Status: ExecutionStatusSent}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.execution.AwaitsResponse())
+		})
+	}
+}
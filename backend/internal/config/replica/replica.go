@@ -0,0 +1,18 @@
+package replica
+
+import (
+	"crypto/rand"
+	"os"
+)
+
+// ID identifies this replica of the backend among the others, e.g. in its MQTT status topic
+// and in the Redis keys of the tasks it waits on.
+var ID = newID()
+
+func newID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "replica"
+	}
+	return host + "-" + rand.Text()[:8]
+}
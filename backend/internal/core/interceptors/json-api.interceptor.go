@@ -1,6 +1,7 @@
 package interceptors
 
 import (
+	"command-dispatcher/internal/utils"
 	"net/http"
 	"strconv"
 
@@ -11,16 +12,22 @@ type JSONAPIResponse struct {
 	JSONAPI struct {
 		Version string `json:"version"`
 	} `json:"jsonapi"`
-	Meta  map[string]interface{} `json:"meta,omitempty"`
-	Links map[string]string      `json:"links,omitempty"`
-	Data  interface{}            `json:"data,omitempty"`
-	Error *JSONAPIError          `json:"error,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+	Links  map[string]string      `json:"links,omitempty"`
+	Data   interface{}            `json:"data,omitempty"`
+	Error  *JSONAPIError          `json:"error,omitempty"`
+	Errors []JSONAPIError         `json:"errors,omitempty"`
 }
 
 type JSONAPIError struct {
-	Status string `json:"status,omitempty"`
-	Title  string `json:"title,omitempty"`
-	Detail string `json:"detail,omitempty"`
+	Status string              `json:"status,omitempty"`
+	Title  string              `json:"title,omitempty"`
+	Detail string              `json:"detail,omitempty"`
+	Source *JSONAPIErrorSource `json:"source,omitempty"`
+}
+
+type JSONAPIErrorSource struct {
+	Pointer string `json:"pointer,omitempty"`
 }
 
 func JsonApiInterceptor() gin.HandlerFunc {
@@ -47,6 +54,7 @@ func JsonApiInterceptor() gin.HandlerFunc {
 			var statusCode int
 			// Only append the first error from the Gin context to the JSON:API response
 			jsonApiResponse.Error, statusCode = handleError(c, err.(string))
+			jsonApiResponse.Errors = handleFieldErrors(c, jsonApiResponse.Error)
 			// Set the status code and send the JSON:API formatted error response
 			c.JSON(statusCode, jsonApiResponse)
 			return
@@ -98,6 +106,25 @@ func handleError(c *gin.Context, err string) (*JSONAPIError, int) {
 	}, statusCode
 }
 
+func handleFieldErrors(c *gin.Context, mainError *JSONAPIError) []JSONAPIError {
+	fieldErrors, exists := c.Get("fieldErrors")
+	if !exists {
+		return nil
+	}
+
+	// Report every invalid field as its own error, pointing at the field in the request document
+	var errors []JSONAPIError
+	for _, fieldError := range fieldErrors.([]utils.FieldError) {
+		errors = append(errors, JSONAPIError{
+			Status: mainError.Status,
+			Title:  mainError.Title,
+			Detail: fieldError.Detail,
+			Source: &JSONAPIErrorSource{Pointer: fieldError.Pointer},
+		})
+	}
+	return errors
+}
+
 func handleGetMetadata(c *gin.Context) *JSONAPIResponse {
 
 	jsonApiResponse := JSONAPIResponse{
@@ -117,6 +144,9 @@ func handleGetMetadata(c *gin.Context) *JSONAPIResponse {
 		if sortOrder, exists := c.GetQuery("sort[order]"); exists {
 			jsonApiResponse.Meta["order"] = sortOrder
 		}
+		if total, exists := c.Get("total"); exists {
+			jsonApiResponse.Meta["total"] = total
+		}
 	}
 
 	return &jsonApiResponse
@@ -1,6 +1,7 @@
 package interceptors
 
 import (
+	"command-dispatcher/internal/utils"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -51,6 +52,46 @@ func TestHandleError(t *testing.T) {
 	}
 }
 
+func TestHandleFieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mainError := &JSONAPIError{Status: "Bad Request", Title: "Invalid parameters"}
+
+	tests := []struct {
+		name           string
+		fieldErrors    []utils.FieldError
+		expectedErrors []JSONAPIError
+	}{
+		{
+			name:           "Should return nothing without field errors",
+			fieldErrors:    nil,
+			expectedErrors: nil,
+		},
+		{
+			name: "Should return one error per field",
+			fieldErrors: []utils.FieldError{
+				{Pointer: "/parameters/0/mode", Detail: "is required"},
+				{Pointer: "/parameters/0/pin", Detail: "must be of type string"},
+			},
+			expectedErrors: []JSONAPIError{
+				{Status: "Bad Request", Title: "Invalid parameters", Detail: "is required", Source: &JSONAPIErrorSource{Pointer: "/parameters/0/mode"}},
+				{Status: "Bad Request", Title: "Invalid parameters", Detail: "must be of type string", Source: &JSONAPIErrorSource{Pointer: "/parameters/0/pin"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			if tt.fieldErrors != nil {
+				c.Set("fieldErrors", tt.fieldErrors)
+			}
+
+			assert.Equal(t, tt.expectedErrors, handleFieldErrors(c, mainError))
+		})
+	}
+}
+
 func TestHandleResponseData(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -100,6 +141,7 @@ func TestHandleGetMetadata(t *testing.T) {
 	tests := []struct {
 		name         string
 		query        string
+		total        interface{}
 		expectedMeta map[string]interface{}
 	}{
 		{
@@ -117,6 +159,12 @@ func TestHandleGetMetadata(t *testing.T) {
 			query:        "",
 			expectedMeta: map[string]interface{}{},
 		},
+		{
+			name:         "Total set by the handler",
+			query:        "?page[number]=1&page[size]=10",
+			total:        int64(42),
+			expectedMeta: map[string]interface{}{"page": 1, "size": 10, "total": int64(42)},
+		},
 	}
 
 	for _, tt := range tests {
@@ -124,6 +172,9 @@ func TestHandleGetMetadata(t *testing.T) {
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 			c.Request, _ = http.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if tt.total != nil {
+				c.Set("total", tt.total)
+			}
 
 			jsonApiResponse := handleGetMetadata(c)
 
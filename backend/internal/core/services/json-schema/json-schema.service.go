@@ -0,0 +1,565 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// JSONSchemaService validates documents against JSON schemas.
+//
+// It supports the subset of JSON Schema draft 2020-12 used to describe command payloads:
+// type, enum, const, the numeric, string, array and object assertions, and the
+// allOf/anyOf/oneOf/not combinators. References ($ref) and other keywords are rejected rather than
+// ignored, so a schema never accepts documents it was meant to rule out.
+type JSONSchemaService struct{}
+
+var (
+	instance *JSONSchemaService
+	once     sync.Once
+)
+
+// NewJSONSchemaService returns a singleton instance of JSONSchemaService.
+func NewJSONSchemaService() *JSONSchemaService {
+	//singleton service
+	once.Do(func() {
+		instance = &JSONSchemaService{}
+	})
+	return instance
+}
+
+// ValidationError describes a value that does not satisfy the schema.
+type ValidationError struct {
+	Path    string `json:"path"` // JSON pointer to the invalid value, "" for the document root
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Schema is a compiled JSON schema.
+type Schema struct {
+	path    string // JSON pointer to the schema within the root schema
+	boolean *bool  // set for the boolean schemas true and false
+
+	types    []string
+	enum     []any
+	constant any
+	hasConst bool
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	items       *Schema
+	prefixItems []*Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *int
+	maxProperties        *int
+}
+
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "string": true, "integer": true,
+}
+
+// keywords are the keywords a schema may use: the supported assertions and applicators,
+// and the annotations, which do not affect validation.
+var keywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"items": true, "prefixItems": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"properties": true, "required": true, "additionalProperties": true, "minProperties": true, "maxProperties": true,
+
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true, "default": true,
+	"examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// Compile parses the schema and checks that it is itself a valid schema.
+// An empty string is treated as the empty schema, which accepts any document.
+func (s *JSONSchemaService) Compile(schema string) (*Schema, error) {
+	if strings.TrimSpace(schema) == "" {
+		schema = "{}"
+	}
+	var raw any
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	return compile(raw, "")
+}
+
+// Validate checks the JSON document against the schema.
+// It returns an error when the schema or the document cannot be parsed, and the list
+// of validation errors otherwise (empty when the document is valid).
+func (s *JSONSchemaService) Validate(schema string, document []byte) ([]ValidationError, error) {
+	compiled, err := s.Compile(schema)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(document, &value); err != nil {
+		return nil, fmt.Errorf("document is not valid JSON: %w", err)
+	}
+	return compiled.Validate(value), nil
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json) against the schema.
+func (sc *Schema) Validate(value any) []ValidationError {
+	return sc.validate(value, "")
+}
+
+func compile(raw any, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		return &Schema{path: path, boolean: &b}, nil
+	}
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return nil, schemaError(path, "schema must be an object or a boolean")
+	}
+	if _, ok := obj["$ref"]; ok {
+		return nil, schemaError(path+"/$ref", "references are not supported")
+	}
+	// Keywords and subschemas are checked in a fixed order so the same schema always reports the same error
+	for _, name := range slices.Sorted(maps.Keys(obj)) {
+		if !keywords[name] {
+			return nil, schemaError(path+"/"+escapePointer(name), "keyword is not supported")
+		}
+	}
+
+	sc := &Schema{path: path}
+	var err error
+
+	if t, ok := obj["type"]; ok {
+		if sc.types, err = compileTypes(t, path+"/type"); err != nil {
+			return nil, err
+		}
+	}
+	if e, ok := obj["enum"]; ok {
+		values, ok := e.([]any)
+		if !ok {
+			return nil, schemaError(path+"/enum", "must be an array")
+		}
+		sc.enum = values
+	}
+	if c, ok := obj["const"]; ok {
+		sc.constant, sc.hasConst = c, true
+	}
+
+	for _, k := range []struct {
+		keyword string
+		target  *[]*Schema
+	}{{"allOf", &sc.allOf}, {"anyOf", &sc.anyOf}, {"oneOf", &sc.oneOf}, {"prefixItems", &sc.prefixItems}} {
+		if v, ok := obj[k.keyword]; ok {
+			if *k.target, err = compileList(v, path+"/"+k.keyword); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, k := range []struct {
+		keyword string
+		target  **Schema
+	}{{"not", &sc.not}, {"items", &sc.items}, {"additionalProperties", &sc.additionalProperties}} {
+		if v, ok := obj[k.keyword]; ok {
+			if *k.target, err = compile(v, path+"/"+k.keyword); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, k := range []struct {
+		keyword string
+		target  **float64
+	}{
+		{"minimum", &sc.minimum}, {"maximum", &sc.maximum},
+		{"exclusiveMinimum", &sc.exclusiveMinimum}, {"exclusiveMaximum", &sc.exclusiveMaximum},
+		{"multipleOf", &sc.multipleOf},
+	} {
+		if v, ok := obj[k.keyword]; ok {
+			n, ok := v.(float64)
+			if !ok {
+				return nil, schemaError(path+"/"+k.keyword, "must be a number")
+			}
+			*k.target = &n
+		}
+	}
+	if sc.multipleOf != nil && *sc.multipleOf <= 0 {
+		return nil, schemaError(path+"/multipleOf", "must be greater than 0")
+	}
+
+	for _, k := range []struct {
+		keyword string
+		target  **int
+	}{
+		{"minLength", &sc.minLength}, {"maxLength", &sc.maxLength},
+		{"minItems", &sc.minItems}, {"maxItems", &sc.maxItems},
+		{"minProperties", &sc.minProperties}, {"maxProperties", &sc.maxProperties},
+	} {
+		if v, ok := obj[k.keyword]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return nil, schemaError(path+"/"+k.keyword, "must be a non-negative integer")
+			}
+			i := int(n)
+			*k.target = &i
+		}
+	}
+
+	if v, ok := obj["pattern"]; ok {
+		p, ok := v.(string)
+		if !ok {
+			return nil, schemaError(path+"/pattern", "must be a string")
+		}
+		if sc.pattern, err = regexp.Compile(p); err != nil {
+			return nil, schemaError(path+"/pattern", "must be a valid regular expression")
+		}
+	}
+	if v, ok := obj["uniqueItems"]; ok {
+		if sc.uniqueItems, ok = v.(bool); !ok {
+			return nil, schemaError(path+"/uniqueItems", "must be a boolean")
+		}
+	}
+
+	if v, ok := obj["properties"]; ok {
+		props, ok := v.(map[string]any)
+		if !ok {
+			return nil, schemaError(path+"/properties", "must be an object")
+		}
+		sc.properties = make(map[string]*Schema, len(props))
+		for _, name := range slices.Sorted(maps.Keys(props)) {
+			if sc.properties[name], err = compile(props[name], path+"/properties/"+escapePointer(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v, ok := obj["required"]; ok {
+		names, ok := v.([]any)
+		if !ok {
+			return nil, schemaError(path+"/required", "must be an array of strings")
+		}
+		for _, n := range names {
+			name, ok := n.(string)
+			if !ok {
+				return nil, schemaError(path+"/required", "must be an array of strings")
+			}
+			sc.required = append(sc.required, name)
+		}
+	}
+
+	return sc, nil
+}
+
+// TypesOutside returns the JSON pointers to the type keywords of the schema and its subschemas
+// that allow none of the given types, so that values of the given types never match.
+// Types under not only rule values out, so they are ignored. The branches of anyOf and oneOf
+// are only reported when none of them can match.
+func (sc *Schema) TypesOutside(allowed ...string) []string {
+	var pointers []string
+	if len(sc.types) > 0 && !slices.ContainsFunc(sc.types, func(t string) bool { return slices.Contains(allowed, t) }) {
+		pointers = append(pointers, sc.path+"/type")
+	}
+	for _, branches := range [][]*Schema{sc.anyOf, sc.oneOf} {
+		pointers = append(pointers, branchTypesOutside(branches, allowed)...)
+	}
+
+	subschemas := slices.Concat(sc.allOf, sc.prefixItems, []*Schema{sc.items, sc.additionalProperties})
+	// Properties are visited in order so the pointers are deterministic
+	for _, name := range slices.Sorted(maps.Keys(sc.properties)) {
+		subschemas = append(subschemas, sc.properties[name])
+	}
+	for _, sub := range subschemas {
+		if sub != nil {
+			pointers = append(pointers, sub.TypesOutside(allowed...)...)
+		}
+	}
+	return pointers
+}
+
+// branchTypesOutside returns the pointers reported by TypesOutside for every branch, or nil if a branch reports none.
+func branchTypesOutside(branches []*Schema, allowed []string) []string {
+	var pointers []string
+	for _, branch := range branches {
+		branchPointers := branch.TypesOutside(allowed...)
+		if len(branchPointers) == 0 {
+			return nil
+		}
+		pointers = append(pointers, branchPointers...)
+	}
+	return pointers
+}
+
+func compileTypes(raw any, path string) ([]string, error) {
+	var names []any
+	switch t := raw.(type) {
+	case string:
+		names = []any{t}
+	case []any:
+		names = t
+	default:
+		return nil, schemaError(path, "must be a string or an array of strings")
+	}
+	types := make([]string, 0, len(names))
+	for _, n := range names {
+		name, ok := n.(string)
+		if !ok || !validTypes[name] {
+			return nil, schemaError(path, fmt.Sprintf("unknown type %v", n))
+		}
+		types = append(types, name)
+	}
+	return types, nil
+}
+
+func compileList(raw any, path string) ([]*Schema, error) {
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return nil, schemaError(path, "must be a non-empty array of schemas")
+	}
+	schemas := make([]*Schema, len(list))
+	for i, item := range list {
+		sc, err := compile(item, path+"/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		schemas[i] = sc
+	}
+	return schemas, nil
+}
+
+func schemaError(path, message string) error {
+	return errors.New(ValidationError{Path: path, Message: message}.Error())
+}
+
+func (sc *Schema) validate(value any, path string) []ValidationError {
+	if sc.boolean != nil {
+		if *sc.boolean {
+			return nil
+		}
+		return []ValidationError{{Path: path, Message: "no value is allowed here"}}
+	}
+
+	var errs []ValidationError
+	fail := func(format string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(sc.types) > 0 && !matchesAnyType(value, sc.types) {
+		fail("must be of type %s", strings.Join(sc.types, " or "))
+		// The remaining assertions are meaningless for a value of the wrong type
+		return errs
+	}
+	if sc.enum != nil && !containsValue(sc.enum, value) {
+		fail("must be one of the allowed values")
+	}
+	if sc.hasConst && !reflect.DeepEqual(sc.constant, value) {
+		fail("must be equal to the constant value")
+	}
+
+	for _, sub := range sc.allOf {
+		errs = append(errs, sub.validate(value, path)...)
+	}
+	if sc.anyOf != nil && countMatches(sc.anyOf, value, path) == 0 {
+		fail("must match at least one schema in anyOf")
+	}
+	if sc.oneOf != nil && countMatches(sc.oneOf, value, path) != 1 {
+		fail("must match exactly one schema in oneOf")
+	}
+	if sc.not != nil && len(sc.not.validate(value, path)) == 0 {
+		fail("must not match the schema in not")
+	}
+
+	switch v := value.(type) {
+	case float64:
+		errs = append(errs, sc.validateNumber(v, path)...)
+	case string:
+		errs = append(errs, sc.validateString(v, path)...)
+	case []any:
+		errs = append(errs, sc.validateArray(v, path)...)
+	case map[string]any:
+		errs = append(errs, sc.validateObject(v, path)...)
+	}
+	return errs
+}
+
+func (sc *Schema) validateNumber(v float64, path string) []ValidationError {
+	var errs []ValidationError
+	fail := func(format string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if sc.minimum != nil && v < *sc.minimum {
+		fail("must be greater than or equal to %v", *sc.minimum)
+	}
+	if sc.maximum != nil && v > *sc.maximum {
+		fail("must be less than or equal to %v", *sc.maximum)
+	}
+	if sc.exclusiveMinimum != nil && v <= *sc.exclusiveMinimum {
+		fail("must be greater than %v", *sc.exclusiveMinimum)
+	}
+	if sc.exclusiveMaximum != nil && v >= *sc.exclusiveMaximum {
+		fail("must be less than %v", *sc.exclusiveMaximum)
+	}
+	if sc.multipleOf != nil {
+		q := v / *sc.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("must be a multiple of %v", *sc.multipleOf)
+		}
+	}
+	return errs
+}
+
+func (sc *Schema) validateString(v string, path string) []ValidationError {
+	var errs []ValidationError
+	length := utf8.RuneCountInString(v)
+	if sc.minLength != nil && length < *sc.minLength {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must be at least %d characters long", *sc.minLength)})
+	}
+	if sc.maxLength != nil && length > *sc.maxLength {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must be at most %d characters long", *sc.maxLength)})
+	}
+	if sc.pattern != nil && !sc.pattern.MatchString(v) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must match the pattern %s", sc.pattern.String())})
+	}
+	return errs
+}
+
+func (sc *Schema) validateArray(v []any, path string) []ValidationError {
+	var errs []ValidationError
+	if sc.minItems != nil && len(v) < *sc.minItems {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must contain at least %d items", *sc.minItems)})
+	}
+	if sc.maxItems != nil && len(v) > *sc.maxItems {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must contain at most %d items", *sc.maxItems)})
+	}
+	if sc.uniqueItems {
+		for i := range v {
+			if containsValue(v[:i], v[i]) {
+				errs = append(errs, ValidationError{Path: path, Message: "must not contain duplicate items"})
+				break
+			}
+		}
+	}
+	for i, item := range v {
+		itemPath := path + "/" + strconv.Itoa(i)
+		if i < len(sc.prefixItems) {
+			errs = append(errs, sc.prefixItems[i].validate(item, itemPath)...)
+		} else if sc.items != nil {
+			errs = append(errs, sc.items.validate(item, itemPath)...)
+		}
+	}
+	return errs
+}
+
+func (sc *Schema) validateObject(v map[string]any, path string) []ValidationError {
+	var errs []ValidationError
+	if sc.minProperties != nil && len(v) < *sc.minProperties {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at least %d properties", *sc.minProperties)})
+	}
+	if sc.maxProperties != nil && len(v) > *sc.maxProperties {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at most %d properties", *sc.maxProperties)})
+	}
+	for _, name := range sc.required {
+		if _, ok := v[name]; !ok {
+			errs = append(errs, ValidationError{Path: path + "/" + escapePointer(name), Message: "is required"})
+		}
+	}
+
+	// Iterate in a stable order so errors are reported deterministically
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		propPath := path + "/" + escapePointer(name)
+		if prop, ok := sc.properties[name]; ok {
+			errs = append(errs, prop.validate(v[name], propPath)...)
+		} else if sc.additionalProperties != nil {
+			errs = append(errs, sc.additionalProperties.validate(v[name], propPath)...)
+		}
+	}
+	return errs
+}
+
+func matchesAnyType(value any, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "object":
+			if _, ok := value.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]any); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := value.(float64); ok && n == math.Trunc(n) {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func countMatches(schemas []*Schema, value any, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		if len(sub.validate(value, path)) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// escapePointer escapes a property name for use as a JSON pointer segment (RFC 6901).
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
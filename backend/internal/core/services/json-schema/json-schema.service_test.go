@@ -0,0 +1,228 @@
+package jsonschema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSchemaServiceSingleton(t *testing.T) {
+	service1 := NewJSONSchemaService()
+	service2 := NewJSONSchemaService()
+
+	if service1 != service2 {
+		t.Errorf("Expected singleton instance, but got different instances")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	service := NewJSONSchemaService()
+
+	tests := []struct {
+		name      string
+		schema    string
+		expectErr bool
+	}{
+		{name: "should accept an empty string", schema: "", expectErr: false},
+		{name: "should accept the empty schema", schema: "{}", expectErr: false},
+		{name: "should accept a boolean schema", schema: "false", expectErr: false},
+		{
+			name:      "should accept a nested schema",
+			schema:    `{"type":"object","properties":{"level":{"type":"integer","minimum":0}},"required":["level"],"additionalProperties":false}`,
+			expectErr: false,
+		},
+		{name: "should reject invalid JSON", schema: `{"type":`, expectErr: true},
+		{name: "should reject a non-object schema", schema: `[]`, expectErr: true},
+		{name: "should reject an unknown type", schema: `{"type":"float"}`, expectErr: true},
+		{name: "should reject a non-string required entry", schema: `{"required":[1]}`, expectErr: true},
+		{name: "should reject an invalid pattern", schema: `{"pattern":"("}`, expectErr: true},
+		{name: "should reject a negative length", schema: `{"minLength":-1}`, expectErr: true},
+		{name: "should reject an invalid nested schema", schema: `{"properties":{"a":{"type":1}}}`, expectErr: true},
+		{name: "should accept annotations", schema: `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"x","examples":[1]}`, expectErr: false},
+		{name: "should reject format", schema: `{"type":"string","format":"email"}`, expectErr: true},
+		{name: "should reject patternProperties", schema: `{"patternProperties":{"^a":{"type":"string"}}}`, expectErr: true},
+		{name: "should reject if/then/else", schema: `{"if":{"minimum":0},"then":{"maximum":10}}`, expectErr: true},
+		{name: "should reject contains", schema: `{"contains":{"type":"string"}}`, expectErr: true},
+		{name: "should reject dependentRequired", schema: `{"dependentRequired":{"a":["b"]}}`, expectErr: true},
+		{name: "should reject $defs", schema: `{"$defs":{"a":{"type":"string"}}}`, expectErr: true},
+		{name: "should reject an unsupported nested keyword", schema: `{"items":{"format":"date"}}`, expectErr: true},
+		{name: "should reject references", schema: `{"$ref":"#/$defs/a"}`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Compile(tt.schema)
+			assert.Equal(t, tt.expectErr, err != nil, "unexpected error state: %v", err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	service := NewJSONSchemaService()
+	schema := `{
+		"type": "array",
+		"minItems": 1,
+		"items": {
+			"type": "object",
+			"properties": {
+				"mode": {"enum": ["fast", "slow"]},
+				"pin": {"type": "string", "pattern": "^[0-9]+$"}
+			},
+			"required": ["mode"],
+			"additionalProperties": false
+		}
+	}`
+
+	tests := []struct {
+		name           string
+		document       string
+		expectedErrors []ValidationError
+	}{
+		{
+			name:     "should accept a valid document",
+			document: `[{"mode":"fast","pin":"12"}]`,
+		},
+		{
+			name:           "should report the wrong root type",
+			document:       `null`,
+			expectedErrors: []ValidationError{{Path: "", Message: "must be of type array"}},
+		},
+		{
+			name:           "should report too few items",
+			document:       `[]`,
+			expectedErrors: []ValidationError{{Path: "", Message: "must contain at least 1 items"}},
+		},
+		{
+			name:     "should report field level errors",
+			document: `[{"pin":"ab","extra":"x"}]`,
+			expectedErrors: []ValidationError{
+				{Path: "/0/mode", Message: "is required"},
+				{Path: "/0/extra", Message: "no value is allowed here"},
+				{Path: "/0/pin", Message: "must match the pattern ^[0-9]+$"},
+			},
+		},
+		{
+			name:           "should report a value outside the enum",
+			document:       `[{"mode":"medium"}]`,
+			expectedErrors: []ValidationError{{Path: "/0/mode", Message: "must be one of the allowed values"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := service.Validate(schema, []byte(tt.document))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedErrors, errs)
+		})
+	}
+}
+
+func TestCompileErrorIsStable(t *testing.T) {
+	service := NewJSONSchemaService()
+
+	tests := []struct {
+		name     string
+		schema   string
+		expected string
+	}{
+		{
+			name:     "should report the first invalid keyword",
+			schema:   `{"not":{"type":"x"},"anyOf":[{"type":"y"}],"allOf":[{"type":"z"}],"minLength":-1,"minimum":"a"}`,
+			expected: "/allOf/0/type: unknown type z",
+		},
+		{
+			name:     "should report the first invalid keyword of the same kind",
+			schema:   `{"maxItems":"a","minLength":-1,"maximum":"b","minimum":"c"}`,
+			expected: "/minimum: must be a number",
+		},
+		{
+			name:     "should report the first invalid property",
+			schema:   `{"properties":{"c":{"type":"x"},"a":{"type":"y"},"b":{"type":"z"}}}`,
+			expected: "/properties/a/type: unknown type y",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order is random, so the schema is compiled several times
+			for range 20 {
+				_, err := service.Compile(tt.schema)
+				assert.EqualError(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateKeywords(t *testing.T) {
+	service := NewJSONSchemaService()
+
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		valid    bool
+	}{
+		{name: "should accept an integer", schema: `{"type":"integer"}`, document: `3`, valid: true},
+		{name: "should reject a fraction as integer", schema: `{"type":"integer"}`, document: `3.5`, valid: false},
+		{name: "should accept one of several types", schema: `{"type":["string","null"]}`, document: `null`, valid: true},
+		{name: "should enforce exclusiveMaximum", schema: `{"exclusiveMaximum":10}`, document: `10`, valid: false},
+		{name: "should enforce multipleOf", schema: `{"multipleOf":0.5}`, document: `1.5`, valid: true},
+		{name: "should count characters not bytes", schema: `{"maxLength":2}`, document: `"hà"`, valid: true},
+		{name: "should enforce uniqueItems", schema: `{"uniqueItems":true}`, document: `[1,2,1]`, valid: false},
+		{name: "should enforce const", schema: `{"const":{"a":1}}`, document: `{"a":1}`, valid: true},
+		{name: "should enforce anyOf", schema: `{"anyOf":[{"type":"string"},{"minimum":5}]}`, document: `3`, valid: false},
+		{name: "should enforce oneOf", schema: `{"oneOf":[{"minimum":1},{"maximum":5}]}`, document: `3`, valid: false},
+		{name: "should enforce not", schema: `{"not":{"type":"null"}}`, document: `null`, valid: false},
+		{name: "should enforce prefixItems", schema: `{"prefixItems":[{"type":"string"}],"items":{"type":"number"}}`, document: `["a",1,2]`, valid: true},
+		{name: "should ignore annotations", schema: `{"title":"x","description":"y","default":"z"}`, document: `"a"`, valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := service.Validate(tt.schema, []byte(tt.document))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, len(errs) == 0, "unexpected errors: %v", errs)
+		})
+	}
+}
+
+func TestTypesOutside(t *testing.T) {
+	service := NewJSONSchemaService()
+
+	tests := []struct {
+		name             string
+		schema           string
+		expectedPointers []string
+	}{
+		{name: "should accept a schema without types", schema: `{"minLength":1}`},
+		{name: "should accept an allowed type", schema: `{"type":"array","items":{"type":"object"}}`},
+		{name: "should accept a list including an allowed type", schema: `{"type":["integer","string"]}`},
+		{name: "should report the root type", schema: `{"type":"integer"}`, expectedPointers: []string{"/type"}},
+		{
+			name:             "should report nested types",
+			schema:           `{"type":"array","items":{"properties":{"b":{"type":"boolean"},"a":{"allOf":[{"type":"number"}]}}}}`,
+			expectedPointers: []string{"/items/properties/a/allOf/0/type", "/items/properties/b/type"},
+		},
+		{name: "should ignore types under not", schema: `{"not":{"type":"number"}}`},
+		{name: "should accept anyOf with an allowed branch", schema: `{"anyOf":[{"type":"string"},{"type":"number"}]}`},
+		{name: "should accept oneOf with an allowed branch", schema: `{"oneOf":[{"type":"null"},{"items":{"type":"string"}}]}`},
+		{
+			name:             "should report anyOf without an allowed branch",
+			schema:           `{"anyOf":[{"type":"number"},{"type":["boolean","null"]}]}`,
+			expectedPointers: []string{"/anyOf/0/type", "/anyOf/1/type"},
+		},
+		{
+			name:             "should report oneOf without an allowed branch",
+			schema:           `{"oneOf":[{"type":"integer"},{"properties":{"a":{"type":"number"}}}]}`,
+			expectedPointers: []string{"/oneOf/0/type", "/oneOf/1/properties/a/type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := service.Compile(tt.schema)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPointers, compiled.TypesOutside("array", "object", "string"))
+		})
+	}
+}
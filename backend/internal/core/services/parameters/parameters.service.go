@@ -0,0 +1,60 @@
+package parameters
+
+import (
+	jsonschema "command-dispatcher/internal/core/services/json-schema"
+	"command-dispatcher/internal/utils"
+	"encoding/json"
+	"sync"
+)
+
+// parameterTypes are the JSON types of a parameters document: an array of objects whose values are strings.
+var parameterTypes = []string{"array", "object", "string"}
+
+// ParametersService checks the parameters of a command against the payload schema of its configuration.
+type ParametersService struct {
+	schemas *jsonschema.JSONSchemaService
+}
+
+var (
+	instance *ParametersService
+	once     sync.Once
+)
+
+// NewParametersService returns a singleton instance of ParametersService.
+func NewParametersService() *ParametersService {
+	//singleton service
+	once.Do(func() {
+		instance = &ParametersService{schemas: jsonschema.NewJSONSchemaService()}
+	})
+	return instance
+}
+
+// CheckSchema checks that the payload schema is a valid schema that parameters can satisfy.
+// Parameter values are strings, so a schema requiring a number, boolean or null anywhere is rejected.
+func (s *ParametersService) CheckSchema(schema string) error {
+	compiled, err := s.schemas.Compile(schema)
+	if err != nil {
+		return err
+	}
+	if pointers := compiled.TypesOutside(parameterTypes...); len(pointers) > 0 {
+		return jsonschema.ValidationError{Path: pointers[0], Message: "parameter values are strings, other types never match"}
+	}
+	return nil
+}
+
+// Check validates the parameters against the payload schema. It returns an error when the schema cannot be
+// compiled, and otherwise one field error per violation, pointing at the invalid value under /parameters.
+func (s *ParametersService) Check(schema string, parameters []map[string]string) ([]utils.FieldError, error) {
+	// Marshalling maps of strings cannot fail
+	document, _ := json.Marshal(parameters)
+	violations, err := s.schemas.Validate(schema, document)
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldErrors []utils.FieldError
+	for _, violation := range violations {
+		fieldErrors = append(fieldErrors, utils.FieldError{Pointer: "/parameters" + violation.Path, Detail: violation.Message})
+	}
+	return fieldErrors, nil
+}
@@ -0,0 +1,102 @@
+package parameters
+
+import (
+	"command-dispatcher/internal/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParametersServiceSingleton(t *testing.T) {
+	service1 := NewParametersService()
+	service2 := NewParametersService()
+
+	if service1 != service2 {
+		t.Errorf("Expected singleton instance, but got different instances")
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	service := NewParametersService()
+
+	tests := []struct {
+		name      string
+		schema    string
+		expectErr bool
+	}{
+		{name: "should accept an empty schema", schema: "", expectErr: false},
+		{name: "should accept string parameters", schema: `{"type":"array","items":{"type":"object","additionalProperties":{"type":"string"}}}`, expectErr: false},
+		{name: "should reject an invalid schema", schema: `{"format":"email"}`, expectErr: true},
+		{name: "should reject an integer parameter", schema: `{"items":{"properties":{"level":{"type":"integer"}}}}`, expectErr: true},
+		{name: "should reject a boolean parameter", schema: `{"items":{"additionalProperties":{"type":"boolean"}}}`, expectErr: true},
+		{name: "should accept a parameter that is not a number", schema: `{"items":{"additionalProperties":{"not":{"type":"number"}}}}`, expectErr: false},
+		{name: "should accept a parameter that may be a string", schema: `{"items":{"additionalProperties":{"anyOf":[{"type":"string"},{"type":"number"}]}}}`, expectErr: false},
+		{name: "should reject a parameter that is never a string", schema: `{"items":{"additionalProperties":{"oneOf":[{"type":"integer"},{"type":"boolean"}]}}}`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.CheckSchema(tt.schema)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	service := NewParametersService()
+	schema := `{"type":"array","items":{"type":"object","properties":{"mode":{"enum":["fast","slow"]}},"required":["mode"]}}`
+
+	tests := []struct {
+		name           string
+		schema         string
+		parameters     []map[string]string
+		expectedErrors []utils.FieldError
+		expectErr      bool
+	}{
+		{
+			name:       "should accept matching parameters",
+			schema:     schema,
+			parameters: []map[string]string{{"mode": "fast"}},
+		},
+		{
+			name:       "should accept any parameters without a schema",
+			schema:     "",
+			parameters: []map[string]string{{"any": "value"}},
+		},
+		{
+			name:       "should point at the invalid parameters",
+			schema:     schema,
+			parameters: []map[string]string{{"mode": "fast"}, {"mode": "medium"}, {}},
+			expectedErrors: []utils.FieldError{
+				{Pointer: "/parameters/1/mode", Detail: "must be one of the allowed values"},
+				{Pointer: "/parameters/2/mode", Detail: "is required"},
+			},
+		},
+		{
+			name:           "should check missing parameters as an empty document",
+			schema:         `{"type":"array","minItems":1}`,
+			expectedErrors: []utils.FieldError{{Pointer: "/parameters", Detail: "must be of type array"}},
+		},
+		{
+			name:      "should fail on an invalid schema",
+			schema:    `{"type":`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldErrors, err := service.Check(tt.schema, tt.parameters)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedErrors, fieldErrors)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/utils"
+)
+
+type GetBatchQuery struct {
+	Page   utils.Page `json:"page,omitempty"`
+	Sort   utils.Sort `json:"sort,omitempty"`
+	Filter struct {
+		CommandConfigID string `json:"commandConfigId,omitempty" form:"filter[commandConfigId]" validate:"omitempty,uuid"`
+	} `json:"filter,omitempty"`
+}
+
+func (q GetBatchQuery) GetPage() utils.Page { return q.Page }
+
+func (q GetBatchQuery) GetSort() utils.Sort { return q.Sort }
+
+// RolloutDTO dispatches a batch in waves: a canary wave first, then waves of wavePercent of the devices,
+// each starting once successThreshold percent of the previous wave completed.
+type RolloutDTO struct {
+	CanaryPercent    int `json:"canaryPercent" validate:"required,min=1,max=100"`
+	WavePercent      int `json:"wavePercent,omitempty" validate:"omitempty,min=1,max=100"` // Defaults to the remaining devices
+	SuccessThreshold int `json:"successThreshold" validate:"required,min=1,max=100"`
+	MaxFailureRate   int `json:"maxFailureRate,omitempty" validate:"omitempty,min=1,max=100"` // Pause as soon as this share of the wave failed
+}
+
+// ToPolicy converts DTO to the rollout policy of a batch
+func (dto *RolloutDTO) ToPolicy() *db.RolloutPolicy {
+	return &db.RolloutPolicy{
+		CanaryPercent:    dto.CanaryPercent,
+		WavePercent:      dto.WavePercent,
+		SuccessThreshold: dto.SuccessThreshold,
+		MaxFailureRate:   dto.MaxFailureRate,
+	}
+}
@@ -1,10 +1,25 @@
 package models
 
+import "command-dispatcher/internal/config/db"
+
 type CommandCreateDTO struct {
-	Description string              `json:"description"`
-	DeviceID    string              `json:"deviceId" validate:"required"`
-	Type        string              `json:"type" validate:"required"`
-	Parameters  []map[string]string `json:"parameters"`
+	Description            string              `json:"description"`
+	DeviceID               string              `json:"deviceId" validate:"required"`
+	Type                   string              `json:"type" validate:"required"`
+	Parameters             []map[string]string `json:"parameters"`
+	CommandConfigID        string              `json:"commandConfigId,omitempty"`
+	AcknowledgementTimeout int                 `json:"acknowledgementTimeout,omitempty"` // Seconds, taken from the command config
+	CompletionTimeout      int                 `json:"completionTimeout,omitempty"`      // Seconds, taken from the command config
+	IsAcknowledgeRequired  bool                `json:"isAcknowledgeRequired"`
+	IsCompletionRequired   bool                `json:"isCompletionRequired"`
+	MaxRetries             int                 `json:"maxRetries,omitempty"`
+	RetryBackoff           string              `json:"retryBackoff,omitempty"`
+	RetryDelay             int                 `json:"retryDelay,omitempty"` // Seconds
+	RetryOn                []string            `json:"retryOn,omitempty"`
+	Priority               string              `json:"priority,omitempty"`
+	AllowConcurrent        bool                `json:"allowConcurrent,omitempty"` // Skip the per-device serialization
+	OfflinePolicy          string              `json:"offlinePolicy,omitempty"`
+	DeferTTL               int                 `json:"deferTtl,omitempty"` // Seconds
 }
 
 type CommandUpdateDTO struct {
@@ -12,3 +27,48 @@ type CommandUpdateDTO struct {
 	Type        string              `json:"type" validate:"required"`
 	Parameters  []map[string]string `json:"parameters"`
 }
+
+type CommandExecuteDTO struct {
+	Description    string              `json:"description"`
+	DeviceID       string              `json:"deviceId,omitempty" validate:"required_without_all=DeviceIDs GroupID Selector,excluded_with=DeviceIDs GroupID Selector"`
+	DeviceIDs      []string            `json:"deviceIds,omitempty" validate:"omitempty,excluded_with=GroupID Selector,max=10000,unique,dive,required"` // Dispatch to several devices as a batch
+	GroupID        string              `json:"groupId,omitempty" validate:"omitempty,uuid,excluded_with=Selector"`                                     // Dispatch to the members of a device group as a batch
+	Selector       string              `json:"selector,omitempty" validate:"omitempty,max=1024"`                                                       // Dispatch to the devices matching a tag selector as a batch, e.g. site=hanoi,model=x2
+	Parameters     []map[string]string `json:"parameters"`
+	Priority       string              `json:"priority,omitempty" validate:"omitempty,oneof=high normal low"` // Overrides the priority of the command config
+	IdempotencyKey string              `json:"idempotencyKey,omitempty" validate:"omitempty,max=255"`         // Deduplicates retried requests, the Idempotency-Key header takes precedence
+	Rollout        *RolloutDTO         `json:"rollout,omitempty" validate:"omitempty,excluded_with=DeviceID"` // Dispatch the batch in waves
+	ScheduleDTO
+}
+
+// ToCommand builds the command to dispatch from the DTO and the referenced configuration
+func (dto *CommandExecuteDTO) ToCommand(config *db.CommandConfig) CommandCreateDTO {
+	priority := config.Priority
+	if dto.Priority != "" {
+		priority = dto.Priority
+	}
+	return CommandCreateDTO{
+		Description:            dto.Description,
+		DeviceID:               dto.DeviceID,
+		Type:                   config.CommandType,
+		Parameters:             dto.Parameters,
+		CommandConfigID:        config.ID,
+		AcknowledgementTimeout: config.AcknowlegmentTimeout,
+		CompletionTimeout:      config.CompletionTimeout,
+		IsAcknowledgeRequired:  config.IsAcknowledgeRequired,
+		IsCompletionRequired:   config.CompletionRequired(),
+		MaxRetries:             config.MaxRetries,
+		RetryBackoff:           config.RetryBackoff,
+		RetryDelay:             config.RetryDelay,
+		RetryOn:                config.RetryOn,
+		Priority:               priority,
+		AllowConcurrent:        config.AllowConcurrent,
+		OfflinePolicy:          config.OfflinePolicy,
+		DeferTTL:               config.DeferTTL,
+	}
+}
+
+// IsBatch reports whether the DTO targets several devices rather than a single one.
+func (dto *CommandExecuteDTO) IsBatch() bool {
+	return dto.DeviceID == ""
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"command-dispatcher/internal/config/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToCommand(t *testing.T) {
+	config := &db.CommandConfig{
+		CommandType:           "reboot",
+		AcknowlegmentTimeout:  5,
+		CompletionTimeout:     30,
+		IsAcknowledgeRequired: true,
+		Priority:              db.PriorityLow,
+	}
+	config.ID = "config-1"
+
+	tests := []struct {
+		name     string
+		dto      CommandExecuteDTO
+		expected CommandCreateDTO
+	}{
+		{
+			name: "Settings of the config",
+			dto: CommandExecuteDTO{
+				Description: "nightly reboot",
+				DeviceID:    "d1",
+				Parameters:  []map[string]string{{"delay": "10"}},
+			},
+			expected: CommandCreateDTO{
+				Description:            "nightly reboot",
+				DeviceID:               "d1",
+				Type:                   "reboot",
+				Parameters:             []map[string]string{{"delay": "10"}},
+				CommandConfigID:        "config-1",
+				AcknowledgementTimeout: 5,
+				CompletionTimeout:      30,
+				IsAcknowledgeRequired:  true,
+				IsCompletionRequired:   true,
+				Priority:               db.PriorityLow,
+			},
+		},
+		{
+			name: "Priority overridden by the request",
+			dto:  CommandExecuteDTO{DeviceID: "d1", Priority: db.PriorityHigh},
+			expected: CommandCreateDTO{
+				DeviceID:               "d1",
+				Type:                   "reboot",
+				CommandConfigID:        "config-1",
+				AcknowledgementTimeout: 5,
+				CompletionTimeout:      30,
+				IsAcknowledgeRequired:  true,
+				IsCompletionRequired:   true,
+				Priority:               db.PriorityHigh,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dto.ToCommand(config))
+		})
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		dto      CommandExecuteDTO
+		expected bool
+	}{
+		{name: "Single device", dto: CommandExecuteDTO{DeviceID: "d1"}},
+		{name: "Device list", dto: CommandExecuteDTO{DeviceIDs: []string{"d1", "d2"}}, expected: true},
+		{name: "Device group", dto: CommandExecuteDTO{GroupID: "5f0c7a4e-8a53-4a5e-9d8e-3f2b1c0d9e8f"}, expected: true},
+		{name: "Tag selector", dto: CommandExecuteDTO{Selector: "site=hanoi"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dto.IsBatch())
+		})
+	}
+}
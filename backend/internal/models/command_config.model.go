@@ -3,13 +3,22 @@ package models
 import "command-dispatcher/internal/config/db"
 
 type CommandConfigCreateDTO struct {
-	Name                  string `json:"name,omitempty" validate:"required"`
-	Description           string `json:"description,omitempty"`
-	CommandType           string `json:"commandType" validate:"required"`
-	IsAcknowledgeRequired bool   `json:"isAcknowledgeRequired,omitempty"`
-	PayloadSchema         string `json:"payloadSchema,omitempty"`
-	AcknowlegmentTimeout  int    `json:"acknowledgementTimeout,omitempty"`
-	CompletionTimeout     int    `json:"completionTimeout,omitempty"`
+	Name                  string   `json:"name,omitempty" validate:"required"`
+	Description           string   `json:"description,omitempty"`
+	CommandType           string   `json:"commandType" validate:"required"`
+	IsAcknowledgeRequired bool     `json:"isAcknowledgeRequired,omitempty"`
+	IsCompletionRequired  *bool    `json:"isCompletionRequired,omitempty"`
+	PayloadSchema         string   `json:"payloadSchema,omitempty"`
+	AcknowlegmentTimeout  int      `json:"acknowledgementTimeout,omitempty"`
+	CompletionTimeout     int      `json:"completionTimeout,omitempty"`
+	MaxRetries            int      `json:"maxRetries,omitempty" validate:"omitempty,min=0,max=100"`
+	RetryBackoff          string   `json:"retryBackoff,omitempty" validate:"omitempty,oneof=fixed exponential"`
+	RetryDelay            int      `json:"retryDelay,omitempty" validate:"omitempty,min=1"`
+	RetryOn               []string `json:"retryOn,omitempty" validate:"omitempty,dive,oneof=acknowledgementTimeout completionTimeout deviceFailure"`
+	Priority              string   `json:"priority,omitempty" validate:"omitempty,oneof=high normal low"`
+	AllowConcurrent       bool     `json:"allowConcurrent,omitempty"`
+	OfflinePolicy         string   `json:"offlinePolicy,omitempty" validate:"omitempty,oneof=send reject defer"`
+	DeferTTL              int      `json:"deferTtl,omitempty" validate:"omitempty,min=1"`
 }
 
 // ToEntity converts DTO to database entity
@@ -19,20 +28,38 @@ func (dto *CommandConfigCreateDTO) ToEntity() *db.CommandConfig {
 		Description:           dto.Description,
 		CommandType:           dto.CommandType,
 		IsAcknowledgeRequired: dto.IsAcknowledgeRequired,
+		IsCompletionRequired:  dto.IsCompletionRequired,
 		PayloadSchema:         dto.PayloadSchema,
 		AcknowlegmentTimeout:  dto.AcknowlegmentTimeout,
 		CompletionTimeout:     dto.CompletionTimeout,
+		MaxRetries:            dto.MaxRetries,
+		RetryBackoff:          dto.RetryBackoff,
+		RetryDelay:            dto.RetryDelay,
+		RetryOn:               dto.RetryOn,
+		Priority:              dto.Priority,
+		AllowConcurrent:       dto.AllowConcurrent,
+		OfflinePolicy:         dto.OfflinePolicy,
+		DeferTTL:              dto.DeferTTL,
 	}
 }
 
 type CommandConfigUpdateDTO struct {
-	Name                  *string `json:"name"`
-	Description           *string `json:"description"`
-	CommandType           *string `json:"commandType"`
-	IsAcknowledgeRequired *bool   `json:"isAcknowledgeRequired"`
-	PayloadSchema         *string `json:"payloadSchema"`
-	AcknowlegmentTimeout  *int    `json:"acknowledgementTimeout"`
-	CompletionTimeout     *int    `json:"completionTimeout"`
+	Name                  *string   `json:"name"`
+	Description           *string   `json:"description"`
+	CommandType           *string   `json:"commandType"`
+	IsAcknowledgeRequired *bool     `json:"isAcknowledgeRequired"`
+	IsCompletionRequired  *bool     `json:"isCompletionRequired"`
+	PayloadSchema         *string   `json:"payloadSchema"`
+	AcknowlegmentTimeout  *int      `json:"acknowledgementTimeout"`
+	CompletionTimeout     *int      `json:"completionTimeout"`
+	MaxRetries            *int      `json:"maxRetries" validate:"omitempty,min=0,max=100"`
+	RetryBackoff          *string   `json:"retryBackoff" validate:"omitempty,oneof=fixed exponential"`
+	RetryDelay            *int      `json:"retryDelay" validate:"omitempty,min=1"`
+	RetryOn               *[]string `json:"retryOn" validate:"omitempty,dive,oneof=acknowledgementTimeout completionTimeout deviceFailure"`
+	Priority              *string   `json:"priority" validate:"omitempty,oneof=high normal low"`
+	AllowConcurrent       *bool     `json:"allowConcurrent"`
+	OfflinePolicy         *string   `json:"offlinePolicy" validate:"omitempty,oneof=send reject defer"`
+	DeferTTL              *int      `json:"deferTtl" validate:"omitempty,min=1"`
 }
 
 // ApplyTo safely updates entity with non-nil DTO fields
@@ -49,6 +76,9 @@ func (dto *CommandConfigUpdateDTO) ApplyTo(entity *db.CommandConfig) {
 	if dto.IsAcknowledgeRequired != nil {
 		entity.IsAcknowledgeRequired = *dto.IsAcknowledgeRequired
 	}
+	if dto.IsCompletionRequired != nil {
+		entity.IsCompletionRequired = dto.IsCompletionRequired
+	}
 	if dto.PayloadSchema != nil {
 		entity.PayloadSchema = *dto.PayloadSchema
 	}
@@ -58,4 +88,28 @@ func (dto *CommandConfigUpdateDTO) ApplyTo(entity *db.CommandConfig) {
 	if dto.CompletionTimeout != nil {
 		entity.CompletionTimeout = *dto.CompletionTimeout
 	}
+	if dto.MaxRetries != nil {
+		entity.MaxRetries = *dto.MaxRetries
+	}
+	if dto.RetryBackoff != nil {
+		entity.RetryBackoff = *dto.RetryBackoff
+	}
+	if dto.RetryDelay != nil {
+		entity.RetryDelay = *dto.RetryDelay
+	}
+	if dto.RetryOn != nil {
+		entity.RetryOn = *dto.RetryOn
+	}
+	if dto.Priority != nil {
+		entity.Priority = *dto.Priority
+	}
+	if dto.AllowConcurrent != nil {
+		entity.AllowConcurrent = *dto.AllowConcurrent
+	}
+	if dto.OfflinePolicy != nil {
+		entity.OfflinePolicy = *dto.OfflinePolicy
+	}
+	if dto.DeferTTL != nil {
+		entity.DeferTTL = *dto.DeferTTL
+	}
 }
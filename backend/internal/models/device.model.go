@@ -0,0 +1,95 @@
+package models
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type GetDeviceQuery struct {
+	Page   utils.Page `json:"page,omitempty"`
+	Sort   utils.Sort `json:"sort,omitempty"`
+	Filter struct {
+		Status          string `json:"status,omitempty" form:"filter[status]" validate:"omitempty,oneof=UNKNOWN ONLINE OFFLINE"`
+		FirmwareVersion string `json:"firmwareVersion,omitempty" form:"filter[firmwareVersion]"`
+		Tags            string `json:"tags,omitempty" form:"filter[tags]"` // Tag selector, see ParseTagSelector
+	} `json:"filter,omitempty"`
+}
+
+func (q GetDeviceQuery) GetPage() utils.Page { return q.Page }
+
+func (q GetDeviceQuery) GetSort() utils.Sort { return q.Sort }
+
+type DeviceCreateDTO struct {
+	ID          string            `json:"id" validate:"required,max=255,excludesall=/+#"` // Identifier the device uses in its MQTT topics
+	Name        string            `json:"name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Attributes  map[string]any    `json:"attributes,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty" validate:"omitempty,dive,keys,required,excludesall=0x2C=,endkeys,excludesall=0x2C"`
+}
+
+// ToEntity converts DTO to database entity
+func (dto *DeviceCreateDTO) ToEntity() *db.Device {
+	attributes, _ := json.Marshal(dto.Attributes)
+	if dto.Attributes == nil {
+		attributes = json.RawMessage("{}")
+	}
+	return &db.Device{
+		ID:          dto.ID,
+		Name:        dto.Name,
+		Description: dto.Description,
+		Status:      db.DeviceStatusUnknown,
+		Attributes:  attributes,
+		Tags:        tags(dto.Tags),
+	}
+}
+
+type DeviceUpdateDTO struct {
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+	Attributes  *map[string]any    `json:"attributes"`                                                                              // Replaces the known attributes
+	Tags        *map[string]string `json:"tags" validate:"omitempty,dive,keys,required,excludesall=0x2C=,endkeys,excludesall=0x2C"` // Replaces the tags
+}
+
+// ApplyTo safely updates entity with non-nil DTO fields
+func (dto *DeviceUpdateDTO) ApplyTo(entity *db.Device) {
+	if dto.Name != nil {
+		entity.Name = *dto.Name
+	}
+	if dto.Description != nil {
+		entity.Description = *dto.Description
+	}
+	if dto.Attributes != nil {
+		entity.Attributes, _ = json.Marshal(*dto.Attributes)
+	}
+	if dto.Tags != nil {
+		entity.Tags = tags(*dto.Tags)
+	}
+}
+
+// tags returns a non-nil tag map so devices without tags are stored as an empty object.
+func tags(t map[string]string) map[string]string {
+	if t == nil {
+		return map[string]string{}
+	}
+	return t
+}
+
+// ParseTagSelector parses a tag selector such as "site=hanoi,model=x2" into the tags a device must all have.
+func ParseTagSelector(selector string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, term := range strings.Split(selector, ",") {
+		key, value, found := strings.Cut(term, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid selector term %q: expected key=value", strings.TrimSpace(term))
+		}
+		if _, duplicate := tags[key]; duplicate {
+			return nil, fmt.Errorf("invalid selector: tag %q appears twice", key)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagSelector(t *testing.T) {
+	tests := []struct {
+		name      string
+		selector  string
+		expected  map[string]string
+		expectErr bool
+	}{
+		{
+			name:     "Single tag",
+			selector: "site=hanoi",
+			expected: map[string]string{"site": "hanoi"},
+		},
+		{
+			name:     "Several tags with spaces",
+			selector: " site = hanoi , model=x2",
+			expected: map[string]string{"site": "hanoi", "model": "x2"},
+		},
+		{
+			name:     "Empty value",
+			selector: "site=",
+			expected: map[string]string{"site": ""},
+		},
+		{
+			name:     "Value containing equals sign",
+			selector: "query=a=b",
+			expected: map[string]string{"query": "a=b"},
+		},
+		{
+			name:      "Empty selector",
+			selector:  "",
+			expectErr: true,
+		},
+		{
+			name:      "Missing value",
+			selector:  "site",
+			expectErr: true,
+		},
+		{
+			name:      "Missing key",
+			selector:  "=hanoi",
+			expectErr: true,
+		},
+		{
+			name:      "Trailing comma",
+			selector:  "site=hanoi,",
+			expectErr: true,
+		},
+		{
+			name:      "Duplicate tag",
+			selector:  "site=hanoi,site=hue",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := ParseTagSelector(tt.selector)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tags)
+		})
+	}
+}
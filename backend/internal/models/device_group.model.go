@@ -0,0 +1,53 @@
+package models
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/utils"
+)
+
+type GetDeviceGroupQuery struct {
+	Page   utils.Page `json:"page,omitempty"`
+	Sort   utils.Sort `json:"sort,omitempty"`
+	Filter struct {
+		DeviceID string `json:"deviceId,omitempty" form:"filter[deviceId]"` // Groups the device is a member of
+	} `json:"filter,omitempty"`
+}
+
+func (q GetDeviceGroupQuery) GetPage() utils.Page { return q.Page }
+
+func (q GetDeviceGroupQuery) GetSort() utils.Sort { return q.Sort }
+
+type DeviceGroupCreateDTO struct {
+	Name        string   `json:"name" validate:"required,max=255"`
+	Description string   `json:"description,omitempty"`
+	DeviceIDs   []string `json:"deviceIds,omitempty" validate:"omitempty,max=10000,unique,dive,required"` // Initial members
+}
+
+// ToEntity converts DTO to database entity
+func (dto *DeviceGroupCreateDTO) ToEntity() *db.DeviceGroup {
+	return &db.DeviceGroup{
+		Name:        dto.Name,
+		Description: dto.Description,
+	}
+}
+
+type DeviceGroupUpdateDTO struct {
+	Name        *string   `json:"name" validate:"omitempty,max=255"`
+	Description *string   `json:"description"`
+	DeviceIDs   *[]string `json:"deviceIds" validate:"omitempty,max=10000,unique,dive,required"` // Replaces the members
+}
+
+// ApplyTo safely updates entity with non-nil DTO fields
+func (dto *DeviceGroupUpdateDTO) ApplyTo(entity *db.DeviceGroup) {
+	if dto.Name != nil {
+		entity.Name = *dto.Name
+	}
+	if dto.Description != nil {
+		entity.Description = *dto.Description
+	}
+}
+
+// DeviceGroupMembersDTO lists devices to add to a group.
+type DeviceGroupMembersDTO struct {
+	DeviceIDs []string `json:"deviceIds" validate:"required,min=1,max=10000,unique,dive,required"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"command-dispatcher/internal/utils"
+	"time"
+)
+
+type GetExecutionQuery struct {
+	Page   utils.Page `json:"page,omitempty"`
+	Sort   utils.Sort `json:"sort,omitempty"`
+	Filter struct {
+		DeviceID        string    `json:"deviceId,omitempty" form:"filter[deviceId]" validate:"omitempty"`
+		CommandConfigID string    `json:"commandConfigId,omitempty" form:"filter[commandConfigId]" validate:"omitempty,uuid"`
+		BatchID         string    `json:"batchId,omitempty" form:"filter[batchId]" validate:"omitempty,uuid"`
+		Status          string    `json:"status,omitempty" form:"filter[status]" validate:"omitempty,uppercase"`
+		IssuedFrom      time.Time `json:"issuedFrom,omitempty" form:"filter[issuedFrom]"`
+		IssuedTo        time.Time `json:"issuedTo,omitempty" form:"filter[issuedTo]"`
+	} `json:"filter,omitempty"`
+}
+
+func (q GetExecutionQuery) GetPage() utils.Page { return q.Page }
+
+func (q GetExecutionQuery) GetSort() utils.Sort { return q.Sort }
+
+// ScheduleDTO defers a dispatch to a given time or by a delay. Leaving both empty dispatches immediately.
+type ScheduleDTO struct {
+	ExecuteAt *time.Time `json:"executeAt,omitempty"`
+	ExecuteIn int        `json:"executeIn,omitempty" validate:"omitempty,min=1,excluded_with=ExecuteAt"` // Seconds
+}
+
+// ProcessAt returns the time the dispatch is scheduled at, or nil if it should happen immediately.
+func (dto *ScheduleDTO) ProcessAt(now time.Time) *time.Time {
+	if dto.ExecuteAt != nil {
+		return dto.ExecuteAt
+	}
+	if dto.ExecuteIn > 0 {
+		at := now.Add(time.Duration(dto.ExecuteIn) * time.Second)
+		return &at
+	}
+	return nil
+}
+
+type RescheduleExecutionDTO struct {
+	ScheduleDTO
+}
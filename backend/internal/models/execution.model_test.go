@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessAt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := now.Add(2 * time.Hour)
+	in := now.Add(90 * time.Second)
+
+	tests := []struct {
+		name     string
+		dto      ScheduleDTO
+		expected *time.Time
+	}{
+		{name: "Immediately", dto: ScheduleDTO{}},
+		{name: "At a given time", dto: ScheduleDTO{ExecuteAt: &at}, expected: &at},
+		{name: "After a delay", dto: ScheduleDTO{ExecuteIn: 90}, expected: &in},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dto.ProcessAt(now))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package models
+
+import "command-dispatcher/internal/config/db"
+
+type ScheduleCreateDTO struct {
+	Name            string              `json:"name" validate:"required"`
+	Description     string              `json:"description,omitempty"`
+	CommandConfigID string              `json:"commandConfigId" validate:"required,uuid"`
+	CronSpec        string              `json:"cronSpec" validate:"required"`
+	Timezone        string              `json:"timezone,omitempty"` // Defaults to UTC
+	DeviceID        string              `json:"deviceId,omitempty" validate:"required_without_all=GroupID Selector,excluded_with=GroupID Selector"`
+	GroupID         string              `json:"groupId,omitempty" validate:"omitempty,uuid,excluded_with=Selector"` // Target the members of a device group
+	Selector        string              `json:"selector,omitempty" validate:"omitempty,max=1024"`                   // Target the devices matching a tag selector
+	Parameters      []map[string]string `json:"parameters"`
+	Enabled         *bool               `json:"enabled,omitempty"` // Defaults to true
+}
+
+// ToEntity converts DTO to database entity
+func (dto *ScheduleCreateDTO) ToEntity() *db.CommandSchedule {
+	schedule := &db.CommandSchedule{
+		Name:            dto.Name,
+		Description:     dto.Description,
+		CommandConfigID: dto.CommandConfigID,
+		CronSpec:        dto.CronSpec,
+		Timezone:        dto.Timezone,
+		DeviceID:        dto.DeviceID,
+		Selector:        dto.Selector,
+		Parameters:      dto.Parameters,
+		Enabled:         dto.Enabled == nil || *dto.Enabled,
+	}
+	if dto.GroupID != "" {
+		schedule.GroupID = &dto.GroupID
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	return schedule
+}
+
+type ScheduleUpdateDTO struct {
+	Name            *string              `json:"name"`
+	Description     *string              `json:"description"`
+	CommandConfigID *string              `json:"commandConfigId" validate:"omitempty,uuid"`
+	CronSpec        *string              `json:"cronSpec"`
+	Timezone        *string              `json:"timezone"`
+	DeviceID        *string              `json:"deviceId" validate:"omitempty,excluded_with=GroupID Selector"` // Setting a target replaces the current one
+	GroupID         *string              `json:"groupId" validate:"omitempty,uuid,excluded_with=Selector"`
+	Selector        *string              `json:"selector" validate:"omitempty,max=1024"`
+	Parameters      *[]map[string]string `json:"parameters"`
+	Enabled         *bool                `json:"enabled"`
+}
+
+// ApplyTo safely updates entity with non-nil DTO fields
+func (dto *ScheduleUpdateDTO) ApplyTo(entity *db.CommandSchedule) {
+	if dto.Name != nil {
+		entity.Name = *dto.Name
+	}
+	if dto.Description != nil {
+		entity.Description = *dto.Description
+	}
+	if dto.CommandConfigID != nil {
+		entity.CommandConfigID = *dto.CommandConfigID
+	}
+	if dto.CronSpec != nil {
+		entity.CronSpec = *dto.CronSpec
+	}
+	if dto.Timezone != nil {
+		entity.Timezone = *dto.Timezone
+	}
+	if dto.DeviceID != nil || dto.GroupID != nil || dto.Selector != nil {
+		entity.DeviceID, entity.GroupID, entity.Selector = "", nil, ""
+	}
+	if dto.DeviceID != nil {
+		entity.DeviceID = *dto.DeviceID
+	}
+	if dto.GroupID != nil {
+		entity.GroupID = dto.GroupID
+	}
+	if dto.Selector != nil {
+		entity.Selector = *dto.Selector
+	}
+	if dto.Parameters != nil {
+		entity.Parameters = *dto.Parameters
+	}
+	if dto.Enabled != nil {
+		entity.Enabled = *dto.Enabled
+	}
+}
@@ -2,7 +2,12 @@ package routes
 
 import (
 	"command-dispatcher/internal/core/interceptors"
+	"command-dispatcher/internal/routes/batch"
 	"command-dispatcher/internal/routes/command"
+	"command-dispatcher/internal/routes/device"
+	devicegroup "command-dispatcher/internal/routes/device-group"
+	"command-dispatcher/internal/routes/execution"
+	"command-dispatcher/internal/routes/schedule"
 	"command-dispatcher/internal/routes/users"
 	"os"
 	"time"
@@ -49,6 +54,11 @@ func Init() {
 	// Routes registration
 	users.Register(api)
 	command.Register(api)
+	execution.Register(api)
+	schedule.Register(api)
+	batch.Register(api)
+	device.Register(api)
+	devicegroup.Register(api)
 
 	// Start the Server
 	log.Printf("Server is running on port: %s", port)
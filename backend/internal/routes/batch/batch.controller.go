@@ -0,0 +1,21 @@
+package batch
+
+import (
+	"command-dispatcher/internal/core/pipes"
+	"command-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register sets up the batch routes within the provided Gin router group.
+func Register(r *gin.RouterGroup) {
+	route := r.Group("/batches")
+
+	batchService := NewBatchService()
+
+	route.GET("", pipes.Query[models.GetBatchQuery], batchService.getAll)
+	route.GET("/:id", batchService.getByID)
+	route.POST("/:id/cancel", batchService.cancel)
+	route.POST("/:id/resume", batchService.resume)
+	route.POST("/:id/abort", batchService.abort)
+}
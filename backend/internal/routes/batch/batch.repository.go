@@ -0,0 +1,79 @@
+package batch
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// sortColumns maps the sortable fields of the API to their database columns.
+var sortColumns = map[string]string{
+	"createdAt": "created_at",
+	"status":    "status",
+}
+
+type BatchRepository struct {
+	db *gorm.DB
+}
+
+func NewBatchRepository(database *gorm.DB) *BatchRepository {
+	return &BatchRepository{db: database}
+}
+
+// FindAll returns the page of batches matching the query along with the total number of matches.
+func (r *BatchRepository) FindAll(query *models.GetBatchQuery) ([]db.CommandBatch, int64, error) {
+	var batches []db.CommandBatch
+	var total int64
+
+	qr := r.db.Model(&db.CommandBatch{})
+	if query.Filter.CommandConfigID != "" {
+		qr = qr.Where("command_config_id = ?", query.Filter.CommandConfigID)
+	}
+	// A new session makes the filtered query safe to reuse for both the count and the page
+	qr = qr.Session(&gorm.Session{})
+	if err := qr.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	qr = utils.CreateSorting(qr, query, sortColumns, "created_at desc")
+	qr = utils.CreatePaging(qr, query)
+	if err := qr.Find(&batches).Error; err != nil {
+		return nil, 0, err
+	}
+	return batches, total, nil
+}
+
+func (r *BatchRepository) FindByID(id string) (*db.CommandBatch, error) {
+	var batch db.CommandBatch
+	if err := r.db.First(&batch, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// Progress counts the executions of each batch per status.
+func (r *BatchRepository) Progress(ids ...string) (map[string]map[string]int64, error) {
+	var rows []struct {
+		BatchID string
+		Status  string
+		Count   int64
+	}
+	err := r.db.Model(&db.CommandExecution{}).
+		Select("batch_id, status, count(*) AS count").
+		Where("batch_id IN ?", ids).
+		Group("batch_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	progress := make(map[string]map[string]int64, len(ids))
+	for _, id := range ids {
+		progress[id] = map[string]int64{}
+	}
+	for _, row := range rows {
+		progress[row.BatchID][row.Status] = row.Count
+	}
+	return progress, nil
+}
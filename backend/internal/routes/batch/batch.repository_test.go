@@ -0,0 +1,61 @@
+package batch
+
+import (
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestFindAll(t *testing.T) {
+	database, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var statements []string
+	database.Callback().Query().After("gorm:query").Register("test:record", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	})
+	repo := NewBatchRepository(database)
+
+	tests := []struct {
+		name     string
+		query    func(q *models.GetBatchQuery)
+		expected []string
+	}{
+		{
+			name:  "Every batch, newest first",
+			query: func(q *models.GetBatchQuery) {},
+			expected: []string{
+				`SELECT count(*) FROM "command_batches"`,
+				`SELECT * FROM "command_batches" ORDER BY created_at desc`,
+			},
+		},
+		{
+			name: "Batches of a command config, sorted and paged",
+			query: func(q *models.GetBatchQuery) {
+				q.Filter.CommandConfigID = "c1"
+				q.Sort = utils.Sort{Field: "status", Order: "asc"}
+				q.Page = utils.Page{Size: 10, Number: 2}
+			},
+			expected: []string{
+				`SELECT count(*) FROM "command_batches" WHERE command_config_id = $1`,
+				`SELECT * FROM "command_batches" WHERE command_config_id = $1 ORDER BY status asc LIMIT $2 OFFSET $3`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements = nil
+			query := &models.GetBatchQuery{}
+			tt.query(query)
+			_, _, err := repo.FindAll(query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, statements)
+		})
+	}
+}
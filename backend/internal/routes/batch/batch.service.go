@@ -0,0 +1,174 @@
+package batch
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"command-dispatcher/internal/worker"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchService provides queries and cancellation of command batches.
+type BatchService struct {
+	repo *BatchRepository
+}
+
+// NewBatchService creates a new BatchService instance.
+func NewBatchService() *BatchService {
+	database := db.GetDB()
+	return &BatchService{repo: NewBatchRepository(database)}
+}
+
+// getAll retrieves the command batches matching the query.
+// @Summary List command batches
+// @Description Retrieve command batches with the number of executions per status, with paging and sorting
+// @Tags batches
+// @Produce json
+// @Param filter[commandConfigId] query string false "Command Config ID"
+// @Param page[number] query int false "Page number"
+// @Param page[size] query int false "Page size"
+// @Param sort[field] query string false "Sort field (createdAt, status)"
+// @Param sort[order] query string false "Sort order (asc, desc)"
+// @Success 200 {array} db.CommandBatch
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /batches [get]
+func (s *BatchService) getAll(c *gin.Context) {
+	query := c.MustGet("Query").(models.GetBatchQuery)
+	batches, total, err := s.repo.FindAll(&query)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch batches failed", "Fetch batches failed", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, len(batches))
+	for i := range batches {
+		ids[i] = batches[i].ID
+	}
+	progress, err := s.repo.Progress(ids...)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch batch progress failed", "Fetch batches failed", http.StatusInternalServerError)
+		return
+	}
+	for i := range batches {
+		batches[i].Progress = progress[batches[i].ID]
+	}
+
+	utils.SetTotal(c, total)
+	c.Status(200)
+	c.Set("response", batches)
+}
+
+// getByID retrieves a single command batch by its ID.
+// @Summary Get command batch by ID
+// @Description Retrieve a specific command batch with the number of executions per status.
+// @Description Use GET /executions?filter[batchId]= to list its executions.
+// @Tags batches
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} db.CommandBatch
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /batches/{id} [get]
+func (s *BatchService) getByID(c *gin.Context) {
+	id := c.Param("id")
+	batch, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch batch failed", "Fetch batch failed", http.StatusNotFound)
+		return
+	}
+	progress, err := s.repo.Progress(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch batch progress failed", "Fetch batch failed", http.StatusInternalServerError)
+		return
+	}
+	batch.Progress = progress[id]
+	c.Set("response", batch)
+}
+
+// cancel cancels the unfinished executions of a command batch.
+// @Summary Cancel command batch
+// @Description Stop the rollout of the batch, if any, and cancel every execution that has not finished yet
+// @Tags batches
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} db.CommandBatch
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Batch is not running or paused"
+// @Failure 500 {object} map[string]interface{}
+// @Router /batches/{id}/cancel [post]
+func (s *BatchService) cancel(c *gin.Context) {
+	id := c.Param("id")
+	batch, err := worker.CancelCommandBatch(id)
+	switch {
+	case errors.Is(err, worker.ErrBatchNotFound):
+		utils.HandleHTTPError(c, "Cancel batch failed", "Fetch batch failed", http.StatusNotFound)
+		return
+	case errors.Is(err, worker.ErrBatchNotCancellable):
+		utils.HandleHTTPError(c, "Cancel batch failed", "Batch is not running or paused", http.StatusConflict)
+		return
+	case err != nil:
+		utils.HandleHTTPError(c, "Cancel batch failed: "+err.Error(), "Cancel batch failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", batch)
+}
+
+// resume resumes a paused rollout.
+// @Summary Resume batch rollout
+// @Description Dispatch the next wave of a rollout paused because a wave did not meet its thresholds
+// @Tags batches
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} db.CommandBatch
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Batch is not paused"
+// @Failure 500 {object} map[string]interface{}
+// @Router /batches/{id}/resume [post]
+func (s *BatchService) resume(c *gin.Context) {
+	id := c.Param("id")
+	batch, err := worker.ResumeCommandBatch(id)
+	switch {
+	case errors.Is(err, worker.ErrBatchNotFound):
+		utils.HandleHTTPError(c, "Resume batch failed", "Fetch batch failed", http.StatusNotFound)
+		return
+	case errors.Is(err, worker.ErrBatchNotPaused):
+		utils.HandleHTTPError(c, "Resume batch failed", "Batch is not paused", http.StatusConflict)
+		return
+	case err != nil:
+		utils.HandleHTTPError(c, "Resume batch failed: "+err.Error(), "Resume batch failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", batch)
+}
+
+// abort stops a rollout.
+// @Summary Abort batch rollout
+// @Description Stop dispatching the following waves of a rollout. Executions already dispatched run to completion.
+// @Tags batches
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} db.CommandBatch
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Batch is not rolling out"
+// @Failure 500 {object} map[string]interface{}
+// @Router /batches/{id}/abort [post]
+func (s *BatchService) abort(c *gin.Context) {
+	id := c.Param("id")
+	batch, err := worker.AbortCommandBatch(id)
+	switch {
+	case errors.Is(err, worker.ErrBatchNotFound):
+		utils.HandleHTTPError(c, "Abort batch failed", "Fetch batch failed", http.StatusNotFound)
+		return
+	case errors.Is(err, worker.ErrBatchNotAbortable):
+		utils.HandleHTTPError(c, "Abort batch failed", "Batch is not rolling out", http.StatusConflict)
+		return
+	case err != nil:
+		utils.HandleHTTPError(c, "Abort batch failed: "+err.Error(), "Abort batch failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", batch)
+}
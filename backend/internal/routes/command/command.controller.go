@@ -18,4 +18,5 @@ func Register(r *gin.RouterGroup) {
 	route.GET("/:id", commandService.getByID)
 	route.PATCH("/:id", pipes.Body[models.CommandConfigUpdateDTO], commandService.update)
 	route.DELETE("/:id", commandService.delete)
+	route.POST("/:id/execute", pipes.Body[models.CommandExecuteDTO], commandService.execute)
 }
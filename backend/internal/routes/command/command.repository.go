@@ -41,3 +41,10 @@ func (r *CommandRepository) Update(command *db.CommandConfig) error {
 func (r *CommandRepository) Delete(id string) error {
 	return r.db.Delete(&db.CommandConfig{}, "id = ?", id).Error
 }
+
+// IsDeviceOffline reports whether the device registry last saw the device go offline.
+func (r *CommandRepository) IsDeviceOffline(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&db.Device{}).Where("id = ? AND status = ?", id, db.DeviceStatusOffline).Count(&count).Error
+	return count > 0, err
+}
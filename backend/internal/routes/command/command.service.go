@@ -2,8 +2,14 @@ package command
 
 import (
 	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/core/services/parameters"
 	"command-dispatcher/internal/models"
 	"command-dispatcher/internal/utils"
+	"command-dispatcher/internal/worker"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,19 +27,25 @@ func NewCommandService() *CommandService {
 
 // create handles creating a new command configuration.
 // @Summary Create a new command configuration
-// @Description Create a new command configuration with the provided details
+// @Description Create a new command configuration with the provided details.
+// @Description The payload schema validates the parameters of executions, an array of objects whose values are strings:
+// @Description keywords the validator does not support, and types other than array, object and string, are rejected.
 // @Tags commands
 // @Accept json
 // @Produce json
 // @Param command body models.CommandConfigCreateDTO true "Command Config"
 // @Success 201 {object} db.CommandConfig
-// @Failure 400 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{} "Invalid body or payload schema"
 // @Failure 500 {object} map[string]interface{}
 // @Router /command [post]
 func (s *CommandService) create(c *gin.Context) {
 
 	dto := c.MustGet("Body").(models.CommandConfigCreateDTO)
 
+	if !checkPayloadSchema(c, dto.PayloadSchema) {
+		return
+	}
+
 	// Convert DTO to database model
 	commandConfig := dto.ToEntity()
 
@@ -106,6 +118,10 @@ func (s *CommandService) update(c *gin.Context) {
 		return
 	}
 
+	if dto.PayloadSchema != nil && !checkPayloadSchema(c, *dto.PayloadSchema) {
+		return
+	}
+
 	// Apply DTO updates to entity
 	dto.ApplyTo(command)
 
@@ -133,3 +149,207 @@ func (s *CommandService) delete(c *gin.Context) {
 	}
 	c.Status(204)
 }
+
+// execute dispatches a command configuration to a device.
+// @Summary Execute a command on a device
+// @Description Resolve the command configuration and enqueue its execution for the given device.
+// @Description Set deviceIds instead of deviceId to dispatch to several devices: one execution is enqueued per device
+// @Description and a db.CommandBatch tracking their progress is returned instead of the execution.
+// @Description Set groupId or selector (e.g. site=hanoi,model=x2) instead to dispatch a batch to the members of a device group
+// @Description or the devices whose tags match; the devices are resolved once, when the batch is dispatched.
+// @Description Set rollout along with a batch target to dispatch the batch in waves, pausing when a wave does not meet the thresholds.
+// @Description Set executeAt or executeIn (seconds) to schedule the execution instead of dispatching it immediately.
+// @Description Set priority to override the priority of the command configuration for this execution.
+// @Description Dispatching to an offline device follows the offline policy of the command configuration:
+// @Description reject responds with 409, defer holds the execution as DEFERRED until the device comes online or the TTL expires.
+// @Description Requests repeated with the same Idempotency-Key return the original execution with 200 instead of dispatching again.
+// @Tags commands
+// @Accept json
+// @Produce json
+// @Param id path string true "Command Config ID"
+// @Param command body models.CommandExecuteDTO true "Command Execution"
+// @Param Idempotency-Key header string false "Key deduplicating retried requests"
+// @Success 200 {object} db.CommandExecution "Original execution of a replayed request"
+// @Success 202 {object} db.CommandExecution
+// @Failure 400 {object} map[string]interface{} "Invalid body, unknown device or group, invalid selector, no target device or parameters not matching the payload schema"
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Device offline and the offline policy is reject"
+// @Failure 422 {object} map[string]interface{} "Idempotency key already used for another command, device or kind of dispatch"
+// @Failure 500 {object} map[string]interface{}
+// @Router /command/{id}/execute [post]
+func (s *CommandService) execute(c *gin.Context) {
+	id := c.Param("id")
+	dto := c.MustGet("Body").(models.CommandExecuteDTO)
+	command, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Execute command failed", "Fetch command config failed", http.StatusNotFound)
+		return
+	}
+
+	if !checkParameters(c, command, dto.Parameters) {
+		return
+	}
+	deviceIDs := dto.DeviceIDs
+	if dto.GroupID != "" || dto.Selector != "" {
+		resolved, ok := resolveTargets(c, dto)
+		if !ok {
+			return
+		}
+		deviceIDs = resolved
+	} else if !s.checkDevices(c, dto) {
+		return
+	}
+
+	idempotencyKey := dto.IdempotencyKey
+	if header := c.GetHeader("Idempotency-Key"); header != "" {
+		idempotencyKey = header
+	}
+	if len(idempotencyKey) > 255 {
+		utils.HandleHTTPError(c, "Execute command failed", "Idempotency key must be at most 255 characters")
+		return
+	}
+	// A replayed request returns the original execution even if the device went offline since
+	if idempotencyKey != "" && !dto.IsBatch() {
+		original, err := worker.FindIdempotentExecution(dto.ToCommand(command), idempotencyKey)
+		if original != nil || err != nil {
+			respondDispatch(c, original, err)
+			return
+		}
+	}
+	if !s.checkOnline(c, command, dto) {
+		return
+	}
+
+	opts := worker.DispatchOptions{
+		ProcessAt:      dto.ProcessAt(time.Now()),
+		IdempotencyKey: idempotencyKey,
+	}
+	if dto.IsBatch() {
+		if dto.Rollout != nil {
+			opts.Rollout = dto.Rollout.ToPolicy()
+		}
+		opts.GroupID, opts.Selector = dto.GroupID, dto.Selector
+		batch, err := worker.EnqueueCommandBatch(dto.ToCommand(command), deviceIDs, opts)
+		respondDispatch(c, batch, err)
+		return
+	}
+	execution, err := worker.EnqueueCommandExecutionTask(dto.ToCommand(command), opts)
+	respondDispatch(c, execution, err)
+}
+
+// checkDevices rejects the request when a target device is not in the device registry.
+func (s *CommandService) checkDevices(c *gin.Context, dto models.CommandExecuteDTO) bool {
+	deviceIDs, pointer := dto.DeviceIDs, "/deviceIds"
+	if len(deviceIDs) == 0 {
+		deviceIDs, pointer = []string{dto.DeviceID}, "/deviceId"
+	}
+	unknown, err := worker.FindUnknownDevices(deviceIDs)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch devices failed: "+err.Error(), "Fetch devices failed", http.StatusInternalServerError)
+		return false
+	}
+	if len(unknown) == 0 {
+		return true
+	}
+
+	fieldErrors := make([]utils.FieldError, len(unknown))
+	for i, index := range unknown {
+		fieldPointer := pointer
+		if len(dto.DeviceIDs) > 0 {
+			fieldPointer = fmt.Sprintf("%s/%d", pointer, index)
+		}
+		fieldErrors[i] = utils.FieldError{Pointer: fieldPointer, Detail: "unknown device " + deviceIDs[index]}
+	}
+	utils.HandleHTTPFieldErrors(c, "Unknown target devices", "Unknown device", fieldErrors)
+	return false
+}
+
+// resolveTargets returns the devices of the device group or matching the tag selector of the request.
+func resolveTargets(c *gin.Context, dto models.CommandExecuteDTO) ([]string, bool) {
+	pointer := "/groupId"
+	if dto.Selector != "" {
+		pointer = "/selector"
+	}
+	deviceIDs, err := worker.ResolveTargetDevices(dto.GroupID, dto.Selector)
+	switch {
+	case errors.Is(err, worker.ErrDeviceGroupNotFound):
+		utils.HandleHTTPFieldErrors(c, "Unknown device group", "Unknown device group",
+			[]utils.FieldError{{Pointer: pointer, Detail: "device group does not exist"}})
+		return nil, false
+	case errors.Is(err, worker.ErrInvalidSelector):
+		utils.HandleHTTPFieldErrors(c, "Invalid tag selector: "+err.Error(), "Invalid tag selector",
+			[]utils.FieldError{{Pointer: pointer, Detail: err.Error()}})
+		return nil, false
+	case errors.Is(err, worker.ErrNoTargetDevices):
+		utils.HandleHTTPFieldErrors(c, "No target devices", "No target devices",
+			[]utils.FieldError{{Pointer: pointer, Detail: err.Error()}})
+		return nil, false
+	case err != nil:
+		utils.HandleHTTPError(c, "Resolve target devices failed: "+err.Error(), "Resolve target devices failed", http.StatusInternalServerError)
+		return nil, false
+	}
+	return deviceIDs, true
+}
+
+// checkOnline rejects the immediate dispatch to an offline device of a command whose offline policy is reject.
+// Batches are not rejected as a whole: the executions of their offline devices are recorded as failed instead.
+func (s *CommandService) checkOnline(c *gin.Context, command *db.CommandConfig, dto models.CommandExecuteDTO) bool {
+	if command.OfflinePolicy != db.OfflinePolicyReject || dto.IsBatch() || dto.ProcessAt(time.Now()) != nil {
+		return true
+	}
+	offline, err := s.repo.IsDeviceOffline(dto.DeviceID)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch device failed: "+err.Error(), "Fetch device failed", http.StatusInternalServerError)
+		return false
+	}
+	if offline {
+		utils.HandleHTTPError(c, "Execute command failed", "Device "+dto.DeviceID+" is offline", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// respondDispatch responds with the dispatched execution or batch, or the original one of a replayed request.
+func respondDispatch(c *gin.Context, dispatched any, err error) {
+	switch {
+	case errors.Is(err, worker.ErrIdempotentReplay):
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(200)
+		c.Set("response", dispatched)
+		return
+	case errors.Is(err, worker.ErrIdempotencyKeyMismatch):
+		utils.HandleHTTPError(c, "Execute command failed", "Idempotency key already used for another dispatch", http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		utils.HandleHTTPError(c, "Execute command failed", "Enqueue command failed", http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(202)
+	c.Set("response", dispatched)
+}
+
+// checkPayloadSchema rejects the request when the payload schema is not itself a valid JSON schema,
+// or cannot be satisfied by parameters.
+func checkPayloadSchema(c *gin.Context, schema string) bool {
+	if err := parameters.NewParametersService().CheckSchema(schema); err != nil {
+		utils.HandleHTTPFieldErrors(c, "Invalid payload schema: "+err.Error(), "Invalid payload schema",
+			[]utils.FieldError{{Pointer: "/payloadSchema", Detail: err.Error()}})
+		return false
+	}
+	return true
+}
+
+// checkParameters rejects the request when the parameters do not match the payload schema of the command.
+func checkParameters(c *gin.Context, command *db.CommandConfig, params []map[string]string) bool {
+	fieldErrors, err := parameters.NewParametersService().Check(command.PayloadSchema, params)
+	if err != nil {
+		utils.HandleHTTPError(c, "Validate parameters failed: "+err.Error(), "Invalid payload schema", http.StatusInternalServerError)
+		return false
+	}
+	if len(fieldErrors) > 0 {
+		utils.HandleHTTPFieldErrors(c, "Parameters do not match the payload schema", "Invalid parameters", fieldErrors)
+		return false
+	}
+	return true
+}
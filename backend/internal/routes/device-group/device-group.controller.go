@@ -0,0 +1,23 @@
+package devicegroup
+
+import (
+	"command-dispatcher/internal/core/pipes"
+	"command-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register sets up the device group routes within the provided Gin router group.
+func Register(r *gin.RouterGroup) {
+	route := r.Group("/device-groups")
+
+	deviceGroupService := NewDeviceGroupService()
+
+	route.POST("", pipes.Body[models.DeviceGroupCreateDTO], deviceGroupService.create)
+	route.GET("", pipes.Query[models.GetDeviceGroupQuery], deviceGroupService.getAll)
+	route.GET("/:id", deviceGroupService.getByID)
+	route.PATCH("/:id", pipes.Body[models.DeviceGroupUpdateDTO], deviceGroupService.update)
+	route.DELETE("/:id", deviceGroupService.delete)
+	route.POST("/:id/devices", pipes.Body[models.DeviceGroupMembersDTO], deviceGroupService.addMembers)
+	route.DELETE("/:id/devices/:deviceId", deviceGroupService.removeMember)
+}
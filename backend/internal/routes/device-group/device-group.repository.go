@@ -0,0 +1,113 @@
+package devicegroup
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// membersTable is the join table of the many-to-many relationship between groups and devices.
+const membersTable = "device_group_members"
+
+// sortColumns maps the sortable fields of the API to their database columns.
+var sortColumns = map[string]string{
+	"name":      "name",
+	"createdAt": "created_at",
+}
+
+type DeviceGroupRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceGroupRepository(database *gorm.DB) *DeviceGroupRepository {
+	return &DeviceGroupRepository{db: database}
+}
+
+// Create inserts the group along with its initial members.
+func (r *DeviceGroupRepository) Create(group *db.DeviceGroup, deviceIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(group).Error; err != nil {
+			return err
+		}
+		return addMembers(tx, group.ID, deviceIDs)
+	})
+}
+
+// FindAll returns the page of groups matching the query along with the total number of matches.
+// Members are not loaded.
+func (r *DeviceGroupRepository) FindAll(query *models.GetDeviceGroupQuery) ([]db.DeviceGroup, int64, error) {
+	var groups []db.DeviceGroup
+	var total int64
+
+	qr := r.db.Model(&db.DeviceGroup{})
+	if query.Filter.DeviceID != "" {
+		qr = qr.Where("id IN (?)", r.db.Table(membersTable).Select("device_group_id").Where("device_id = ?", query.Filter.DeviceID))
+	}
+	// A new session makes the filtered query safe to reuse for both the count and the page
+	qr = qr.Session(&gorm.Session{})
+	if err := qr.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	qr = utils.CreateSorting(qr, query, sortColumns, "name")
+	qr = utils.CreatePaging(qr, query)
+	if err := qr.Find(&groups).Error; err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// FindByID returns the group with its members.
+func (r *DeviceGroupRepository) FindByID(id string) (*db.DeviceGroup, error) {
+	var group db.DeviceGroup
+	if err := r.db.Preload("Devices", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).First(&group, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Update saves the group; when deviceIDs is not nil they replace the members.
+func (r *DeviceGroupRepository) Update(group *db.DeviceGroup, deviceIDs *[]string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(group).Error; err != nil {
+			return err
+		}
+		if deviceIDs == nil {
+			return nil
+		}
+		if err := tx.Table(membersTable).Where("device_group_id = ?", group.ID).Delete(map[string]any{}).Error; err != nil {
+			return err
+		}
+		return addMembers(tx, group.ID, *deviceIDs)
+	})
+}
+
+func (r *DeviceGroupRepository) Delete(id string) error {
+	return r.db.Delete(&db.DeviceGroup{}, "id = ?", id).Error
+}
+
+// AddMembers adds the devices to the group. Devices already in the group are ignored.
+func (r *DeviceGroupRepository) AddMembers(id string, deviceIDs []string) error {
+	return addMembers(r.db, id, deviceIDs)
+}
+
+// RemoveMember removes the device from the group, reporting false if it was not a member.
+func (r *DeviceGroupRepository) RemoveMember(id, deviceID string) (bool, error) {
+	result := r.db.Table(membersTable).Where("device_group_id = ? AND device_id = ?", id, deviceID).Delete(map[string]any{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// addMembers inserts the membership rows, without touching the devices themselves.
+func addMembers(tx *gorm.DB, groupID string, deviceIDs []string) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	rows := make([]map[string]any, len(deviceIDs))
+	for i, deviceID := range deviceIDs {
+		rows[i] = map[string]any{"device_group_id": groupID, "device_id": deviceID}
+	}
+	return tx.Table(membersTable).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 1000).Error
+}
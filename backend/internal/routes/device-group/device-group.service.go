@@ -0,0 +1,242 @@
+package devicegroup
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"command-dispatcher/internal/worker"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DeviceGroupService manages the device groups commands can be dispatched to.
+type DeviceGroupService struct {
+	repo *DeviceGroupRepository
+}
+
+// NewDeviceGroupService creates a new DeviceGroupService instance.
+func NewDeviceGroupService() *DeviceGroupService {
+	database := db.GetDB()
+	return &DeviceGroupService{repo: NewDeviceGroupRepository(database)}
+}
+
+// create handles creating a new device group.
+// @Summary Create a device group
+// @Description Create a named group of registered devices. Set the groupId of a dispatch or schedule to target its members.
+// @Tags device-groups
+// @Accept json
+// @Produce json
+// @Param group body models.DeviceGroupCreateDTO true "Device Group"
+// @Success 201 {object} db.DeviceGroup
+// @Failure 400 {object} map[string]interface{} "Invalid body or unknown device"
+// @Failure 409 {object} map[string]interface{} "Group name already used"
+// @Failure 500 {object} map[string]interface{}
+// @Router /device-groups [post]
+func (s *DeviceGroupService) create(c *gin.Context) {
+	dto := c.MustGet("Body").(models.DeviceGroupCreateDTO)
+	if !s.checkDevices(c, dto.DeviceIDs) {
+		return
+	}
+
+	group := dto.ToEntity()
+	if err := s.repo.Create(group, dto.DeviceIDs); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			utils.HandleHTTPError(c, "Create device group failed", "Group name already used", http.StatusConflict)
+			return
+		}
+		utils.HandleHTTPError(c, "Create device group failed: "+err.Error(), "Create device group failed", http.StatusInternalServerError)
+		return
+	}
+
+	s.respondGroup(c, group.ID, 201)
+}
+
+// getAll retrieves the device groups matching the query.
+// @Summary List device groups
+// @Description Retrieve the device groups, without their members, optionally only those a device belongs to, with paging and sorting
+// @Tags device-groups
+// @Produce json
+// @Param filter[deviceId] query string false "Device the groups contain"
+// @Param page[number] query int false "Page number"
+// @Param page[size] query int false "Page size"
+// @Param sort[field] query string false "Sort field (name, createdAt)"
+// @Param sort[order] query string false "Sort order (asc, desc)"
+// @Success 200 {array} db.DeviceGroup
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /device-groups [get]
+func (s *DeviceGroupService) getAll(c *gin.Context) {
+	query := c.MustGet("Query").(models.GetDeviceGroupQuery)
+	groups, total, err := s.repo.FindAll(&query)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch device groups failed", "Fetch device groups failed", http.StatusInternalServerError)
+		return
+	}
+	utils.SetTotal(c, total)
+	c.Status(200)
+	c.Set("response", groups)
+}
+
+// getByID retrieves a single device group by its ID.
+// @Summary Get device group by ID
+// @Description Retrieve a specific device group with its member devices
+// @Tags device-groups
+// @Produce json
+// @Param id path string true "Device Group ID"
+// @Success 200 {object} db.DeviceGroup
+// @Failure 404 {object} map[string]interface{}
+// @Router /device-groups/{id} [get]
+func (s *DeviceGroupService) getByID(c *gin.Context) {
+	s.respondGroup(c, c.Param("id"), 200)
+}
+
+// update updates an existing device group.
+// @Summary Update device group
+// @Description Update the name or description of a device group. Set deviceIds to replace its members.
+// @Tags device-groups
+// @Accept json
+// @Produce json
+// @Param id path string true "Device Group ID"
+// @Param group body models.DeviceGroupUpdateDTO true "Device Group Update"
+// @Success 200 {object} db.DeviceGroup
+// @Failure 400 {object} map[string]interface{} "Invalid body or unknown device"
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Group name already used"
+// @Failure 500 {object} map[string]interface{}
+// @Router /device-groups/{id} [patch]
+func (s *DeviceGroupService) update(c *gin.Context) {
+	id := c.Param("id")
+	dto := c.MustGet("Body").(models.DeviceGroupUpdateDTO)
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch device group failed", "Fetch device group failed", http.StatusNotFound)
+		return
+	}
+	if dto.DeviceIDs != nil && !s.checkDevices(c, *dto.DeviceIDs) {
+		return
+	}
+
+	dto.ApplyTo(group)
+	group.Devices = nil
+
+	if err := s.repo.Update(group, dto.DeviceIDs); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			utils.HandleHTTPError(c, "Update device group failed", "Group name already used", http.StatusConflict)
+			return
+		}
+		utils.HandleHTTPError(c, "Update device group failed: "+err.Error(), "Update device group failed", http.StatusInternalServerError)
+		return
+	}
+	s.respondGroup(c, id, 200)
+}
+
+// delete removes a device group.
+// @Summary Delete device group
+// @Description Delete a device group. Its devices are kept, and batches already dispatched to it are not affected.
+// @Tags device-groups
+// @Param id path string true "Device Group ID"
+// @Success 204 "No Content"
+// @Failure 500 {object} map[string]interface{}
+// @Router /device-groups/{id} [delete]
+func (s *DeviceGroupService) delete(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.repo.Delete(id); err != nil {
+		utils.HandleHTTPError(c, "Delete device group failed", "Delete device group failed", http.StatusInternalServerError)
+		return
+	}
+	c.Status(204)
+}
+
+// addMembers adds devices to a device group.
+// @Summary Add devices to a group
+// @Description Add registered devices to a device group. Devices already in the group are ignored.
+// @Tags device-groups
+// @Accept json
+// @Produce json
+// @Param id path string true "Device Group ID"
+// @Param members body models.DeviceGroupMembersDTO true "Devices to add"
+// @Success 200 {object} db.DeviceGroup
+// @Failure 400 {object} map[string]interface{} "Invalid body or unknown device"
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /device-groups/{id}/devices [post]
+func (s *DeviceGroupService) addMembers(c *gin.Context) {
+	id := c.Param("id")
+	dto := c.MustGet("Body").(models.DeviceGroupMembersDTO)
+	if _, err := s.repo.FindByID(id); err != nil {
+		utils.HandleHTTPError(c, "Fetch device group failed", "Fetch device group failed", http.StatusNotFound)
+		return
+	}
+	if !s.checkDevices(c, dto.DeviceIDs) {
+		return
+	}
+
+	if err := s.repo.AddMembers(id, dto.DeviceIDs); err != nil {
+		utils.HandleHTTPError(c, "Add group members failed: "+err.Error(), "Add group members failed", http.StatusInternalServerError)
+		return
+	}
+	s.respondGroup(c, id, 200)
+}
+
+// removeMember removes a device from a device group.
+// @Summary Remove a device from a group
+// @Description Remove a device from a device group. The device itself is kept.
+// @Tags device-groups
+// @Param id path string true "Device Group ID"
+// @Param deviceId path string true "Device ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} map[string]interface{} "Device is not a member of the group"
+// @Failure 500 {object} map[string]interface{}
+// @Router /device-groups/{id}/devices/{deviceId} [delete]
+func (s *DeviceGroupService) removeMember(c *gin.Context) {
+	removed, err := s.repo.RemoveMember(c.Param("id"), c.Param("deviceId"))
+	if err != nil {
+		utils.HandleHTTPError(c, "Remove group member failed", "Remove group member failed", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		utils.HandleHTTPError(c, "Remove group member failed", "Device is not a member of the group", http.StatusNotFound)
+		return
+	}
+	c.Status(204)
+}
+
+// respondGroup responds with the group and its members.
+func (s *DeviceGroupService) respondGroup(c *gin.Context, id string, status int) {
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch device group failed", "Fetch device group failed", http.StatusNotFound)
+		return
+	}
+	c.Status(status)
+	c.Set("response", group)
+}
+
+// checkDevices rejects the request when a device to add to a group is not in the device registry.
+func (s *DeviceGroupService) checkDevices(c *gin.Context, deviceIDs []string) bool {
+	if len(deviceIDs) == 0 {
+		return true
+	}
+	unknown, err := worker.FindUnknownDevices(deviceIDs)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch devices failed: "+err.Error(), "Fetch devices failed", http.StatusInternalServerError)
+		return false
+	}
+	if len(unknown) == 0 {
+		return true
+	}
+
+	fieldErrors := make([]utils.FieldError, len(unknown))
+	for i, index := range unknown {
+		fieldErrors[i] = utils.FieldError{
+			Pointer: fmt.Sprintf("/deviceIds/%d", index),
+			Detail:  "unknown device " + deviceIDs[index],
+		}
+	}
+	utils.HandleHTTPFieldErrors(c, "Unknown devices", "Unknown device", fieldErrors)
+	return false
+}
@@ -0,0 +1,21 @@
+package device
+
+import (
+	"command-dispatcher/internal/core/pipes"
+	"command-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register sets up the device routes within the provided Gin router group.
+func Register(r *gin.RouterGroup) {
+	route := r.Group("/devices")
+
+	deviceService := NewDeviceService()
+
+	route.POST("", pipes.Body[models.DeviceCreateDTO], deviceService.create)
+	route.GET("", pipes.Query[models.GetDeviceQuery], deviceService.getAll)
+	route.GET("/:id", deviceService.getByID)
+	route.PATCH("/:id", pipes.Body[models.DeviceUpdateDTO], deviceService.update)
+	route.DELETE("/:id", deviceService.delete)
+}
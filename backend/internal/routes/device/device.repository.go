@@ -0,0 +1,78 @@
+package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// sortColumns maps the sortable fields of the API to their database columns.
+var sortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"status":     "status",
+	"lastSeenAt": "last_seen_at",
+}
+
+type DeviceRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceRepository(database *gorm.DB) *DeviceRepository {
+	return &DeviceRepository{db: database}
+}
+
+func (r *DeviceRepository) Create(device *db.Device) error {
+	return r.db.Create(device).Error
+}
+
+// FindAll returns the page of devices matching the query along with the total number of matches.
+func (r *DeviceRepository) FindAll(query *models.GetDeviceQuery) ([]db.Device, int64, error) {
+	var devices []db.Device
+	var total int64
+
+	qr := r.db.Model(&db.Device{})
+	if query.Filter.Status != "" {
+		qr = qr.Where("status = ?", query.Filter.Status)
+	}
+	if query.Filter.FirmwareVersion != "" {
+		qr = qr.Where("firmware_version = ?", query.Filter.FirmwareVersion)
+	}
+	if query.Filter.Tags != "" {
+		// The selector was validated by the service
+		tags, _ := models.ParseTagSelector(query.Filter.Tags)
+		containment, _ := json.Marshal(tags)
+		qr = qr.Where("tags @> ?::jsonb", string(containment))
+	}
+	// A new session makes the filtered query safe to reuse for both the count and the page
+	qr = qr.Session(&gorm.Session{})
+	if err := qr.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	qr = utils.CreateSorting(qr, query, sortColumns, "id")
+	qr = utils.CreatePaging(qr, query)
+	if err := qr.Find(&devices).Error; err != nil {
+		return nil, 0, err
+	}
+	return devices, total, nil
+}
+
+func (r *DeviceRepository) FindByID(id string) (*db.Device, error) {
+	var device db.Device
+	if err := r.db.First(&device, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func (r *DeviceRepository) Update(device *db.Device) error {
+	return r.db.Save(device).Error
+}
+
+func (r *DeviceRepository) Delete(id string) error {
+	return r.db.Delete(&db.Device{}, "id = ?", id).Error
+}
@@ -0,0 +1,153 @@
+package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DeviceService manages the device registry.
+type DeviceService struct {
+	repo *DeviceRepository
+}
+
+// NewDeviceService creates a new DeviceService instance.
+func NewDeviceService() *DeviceService {
+	database := db.GetDB()
+	return &DeviceService{repo: NewDeviceRepository(database)}
+}
+
+// create handles registering a new device.
+// @Summary Register a device
+// @Description Register a device ahead of its first status message. Devices reporting their status are registered automatically.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param device body models.DeviceCreateDTO true "Device"
+// @Success 201 {object} db.Device
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Device already registered"
+// @Failure 500 {object} map[string]interface{}
+// @Router /devices [post]
+func (s *DeviceService) create(c *gin.Context) {
+	dto := c.MustGet("Body").(models.DeviceCreateDTO)
+	device := dto.ToEntity()
+
+	if err := s.repo.Create(device); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			utils.HandleHTTPError(c, "Create device failed", "Device already registered", http.StatusConflict)
+			return
+		}
+		utils.HandleHTTPError(c, "Create device failed: "+err.Error(), "Create device failed", http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(201)
+	c.Set("response", device)
+}
+
+// getAll retrieves the devices matching the query.
+// @Summary List devices
+// @Description Retrieve the known devices with their presence, filtered by status, firmware version or tags, with paging and sorting
+// @Tags devices
+// @Produce json
+// @Param filter[status] query string false "Device status (UNKNOWN, ONLINE, OFFLINE)"
+// @Param filter[firmwareVersion] query string false "Firmware version"
+// @Param filter[tags] query string false "Tag selector the devices must match, e.g. site=hanoi,model=x2"
+// @Param page[number] query int false "Page number"
+// @Param page[size] query int false "Page size"
+// @Param sort[field] query string false "Sort field (id, name, status, lastSeenAt)"
+// @Param sort[order] query string false "Sort order (asc, desc)"
+// @Success 200 {array} db.Device
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /devices [get]
+func (s *DeviceService) getAll(c *gin.Context) {
+	query := c.MustGet("Query").(models.GetDeviceQuery)
+	if query.Filter.Tags != "" {
+		if _, err := models.ParseTagSelector(query.Filter.Tags); err != nil {
+			utils.HandleHTTPError(c, "Invalid tag selector: "+err.Error(), "Invalid filter[tags]: "+err.Error())
+			return
+		}
+	}
+	devices, total, err := s.repo.FindAll(&query)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch devices failed", "Fetch devices failed", http.StatusInternalServerError)
+		return
+	}
+	utils.SetTotal(c, total)
+	c.Status(200)
+	c.Set("response", devices)
+}
+
+// getByID retrieves a single device by its ID.
+// @Summary Get device by ID
+// @Description Retrieve a specific device with its presence, firmware version and reported attributes
+// @Tags devices
+// @Produce json
+// @Param id path string true "Device ID"
+// @Success 200 {object} db.Device
+// @Failure 404 {object} map[string]interface{}
+// @Router /devices/{id} [get]
+func (s *DeviceService) getByID(c *gin.Context) {
+	id := c.Param("id")
+	device, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch device failed", "Fetch device failed", http.StatusNotFound)
+		return
+	}
+	c.Set("response", device)
+}
+
+// update updates an existing device.
+// @Summary Update device
+// @Description Update the name, description, attributes or tags of a device
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Param device body models.DeviceUpdateDTO true "Device Update"
+// @Success 200 {object} db.Device
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /devices/{id} [patch]
+func (s *DeviceService) update(c *gin.Context) {
+	id := c.Param("id")
+	dto := c.MustGet("Body").(models.DeviceUpdateDTO)
+	device, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch device failed", "Fetch device failed", http.StatusNotFound)
+		return
+	}
+
+	dto.ApplyTo(device)
+
+	if err := s.repo.Update(device); err != nil {
+		utils.HandleHTTPError(c, "Update device failed: "+err.Error(), "Update device failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", device)
+}
+
+// delete removes a device from the registry.
+// @Summary Delete device
+// @Description Remove a device from the registry. It is registered again when it next reports its status.
+// @Tags devices
+// @Param id path string true "Device ID"
+// @Success 204 "No Content"
+// @Failure 500 {object} map[string]interface{}
+// @Router /devices/{id} [delete]
+func (s *DeviceService) delete(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.repo.Delete(id); err != nil {
+		utils.HandleHTTPError(c, "Delete device failed", "Delete device failed", http.StatusInternalServerError)
+		return
+	}
+	c.Status(204)
+}
@@ -0,0 +1,20 @@
+package execution
+
+import (
+	"command-dispatcher/internal/core/pipes"
+	"command-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register sets up the execution routes within the provided Gin router group.
+func Register(r *gin.RouterGroup) {
+	route := r.Group("/executions")
+
+	executionService := NewExecutionService()
+
+	route.GET("", pipes.Query[models.GetExecutionQuery], executionService.getAll)
+	route.GET("/:id", executionService.getByID)
+	route.POST("/:id/cancel", executionService.cancel)
+	route.POST("/:id/reschedule", pipes.Body[models.RescheduleExecutionDTO], executionService.reschedule)
+}
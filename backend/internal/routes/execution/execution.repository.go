@@ -0,0 +1,75 @@
+package execution
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// sortColumns maps the sortable fields of the API to their database columns.
+var sortColumns = map[string]string{
+	"issuedAt":    "issued_at",
+	"completedAt": "completed_at",
+	"status":      "status",
+	"deviceId":    "device_id",
+}
+
+type ExecutionRepository struct {
+	db *gorm.DB
+}
+
+func NewExecutionRepository(database *gorm.DB) *ExecutionRepository {
+	return &ExecutionRepository{db: database}
+}
+
+// FindAll returns the page of executions matching the query along with the total number of matches.
+func (r *ExecutionRepository) FindAll(query *models.GetExecutionQuery) ([]db.CommandExecution, int64, error) {
+	var executions []db.CommandExecution
+	var total int64
+
+	// A new session makes the filtered query safe to reuse for both the count and the page
+	qr := r.createFilter(r.db.Model(&db.CommandExecution{}), query).Session(&gorm.Session{})
+	if err := qr.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	qr = utils.CreateSorting(qr, query, sortColumns, "issued_at desc")
+	qr = utils.CreatePaging(qr, query)
+	if err := qr.Find(&executions).Error; err != nil {
+		return nil, 0, err
+	}
+	return executions, total, nil
+}
+
+func (r *ExecutionRepository) FindByID(id string) (*db.CommandExecution, error) {
+	var execution db.CommandExecution
+	if err := r.db.First(&execution, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+func (r *ExecutionRepository) createFilter(qr *gorm.DB, query *models.GetExecutionQuery) *gorm.DB {
+	filter := query.Filter
+	if filter.DeviceID != "" {
+		qr = qr.Where("device_id = ?", filter.DeviceID)
+	}
+	if filter.CommandConfigID != "" {
+		qr = qr.Where("command_config_id = ?", filter.CommandConfigID)
+	}
+	if filter.BatchID != "" {
+		qr = qr.Where("batch_id = ?", filter.BatchID)
+	}
+	if filter.Status != "" {
+		qr = qr.Where("status = ?", filter.Status)
+	}
+	if !filter.IssuedFrom.IsZero() {
+		qr = qr.Where("issued_at >= ?", filter.IssuedFrom)
+	}
+	if !filter.IssuedTo.IsZero() {
+		qr = qr.Where("issued_at <= ?", filter.IssuedTo)
+	}
+	return qr
+}
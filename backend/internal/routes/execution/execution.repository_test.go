@@ -0,0 +1,82 @@
+package execution
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestExecutionQuery(t *testing.T) {
+	database, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	repo := NewExecutionRepository(database)
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    func(q *models.GetExecutionQuery)
+		expected string
+		vars     []any
+	}{
+		{
+			name:     "No filter",
+			query:    func(q *models.GetExecutionQuery) {},
+			expected: `SELECT * FROM "command_executions" ORDER BY issued_at desc`,
+		},
+		{
+			name: "Filtered by device and status",
+			query: func(q *models.GetExecutionQuery) {
+				q.Filter.DeviceID = "d1"
+				q.Filter.Status = db.ExecutionStatusFailed
+			},
+			expected: `SELECT * FROM "command_executions" WHERE device_id = $1 AND status = $2 ORDER BY issued_at desc`,
+		},
+		{
+			name: "Filtered by issue time",
+			query: func(q *models.GetExecutionQuery) {
+				q.Filter.IssuedFrom = from
+				q.Filter.IssuedTo = from.Add(24 * time.Hour)
+			},
+			expected: `SELECT * FROM "command_executions" WHERE issued_at >= $1 AND issued_at <= $2 ORDER BY issued_at desc`,
+		},
+		{
+			name: "Sorted and paged",
+			query: func(q *models.GetExecutionQuery) {
+				q.Sort = utils.Sort{Field: "completedAt", Order: "asc"}
+				q.Page = utils.Page{Size: 20, Number: 3}
+			},
+			expected: `SELECT * FROM "command_executions" ORDER BY completed_at asc LIMIT $1 OFFSET $2`,
+			vars:     []any{20, 40},
+		},
+		{
+			name: "Unknown sort field",
+			query: func(q *models.GetExecutionQuery) {
+				q.Sort = utils.Sort{Field: "error", Order: "asc"}
+			},
+			expected: `SELECT * FROM "command_executions" ORDER BY issued_at desc`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := &models.GetExecutionQuery{}
+			tt.query(query)
+			stmt := repo.createFilter(database.Model(&db.CommandExecution{}), query)
+			stmt = utils.CreatePaging(utils.CreateSorting(stmt, query, sortColumns, "issued_at desc"), query)
+			stmt = stmt.Find(&[]db.CommandExecution{})
+			assert.NoError(t, stmt.Error)
+			assert.Equal(t, tt.expected, stmt.Statement.SQL.String())
+			if tt.vars != nil {
+				assert.Equal(t, tt.vars, stmt.Statement.Vars)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package execution
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"command-dispatcher/internal/worker"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExecutionService provides command execution history queries.
+type ExecutionService struct {
+	repo *ExecutionRepository
+}
+
+// NewExecutionService creates a new ExecutionService instance.
+func NewExecutionService() *ExecutionService {
+	database := db.GetDB()
+	return &ExecutionService{repo: NewExecutionRepository(database)}
+}
+
+// getAll retrieves the command executions matching the query.
+// @Summary List command executions
+// @Description Retrieve command executions filtered by device, command configuration, batch, status and issue date, with paging and sorting
+// @Tags executions
+// @Produce json
+// @Param filter[deviceId] query string false "Device ID"
+// @Param filter[commandConfigId] query string false "Command Config ID"
+// @Param filter[batchId] query string false "Batch ID"
+// @Param filter[status] query string false "Execution status"
+// @Param filter[issuedFrom] query string false "Issued at or after (RFC3339)"
+// @Param filter[issuedTo] query string false "Issued at or before (RFC3339)"
+// @Param page[number] query int false "Page number"
+// @Param page[size] query int false "Page size"
+// @Param sort[field] query string false "Sort field (issuedAt, completedAt, status, deviceId)"
+// @Param sort[order] query string false "Sort order (asc, desc)"
+// @Success 200 {array} db.CommandExecution
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /executions [get]
+func (s *ExecutionService) getAll(c *gin.Context) {
+	query := c.MustGet("Query").(models.GetExecutionQuery)
+	executions, total, err := s.repo.FindAll(&query)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch executions failed", "Fetch executions failed", http.StatusInternalServerError)
+		return
+	}
+	utils.SetTotal(c, total)
+	c.Status(200)
+	c.Set("response", executions)
+}
+
+// getByID retrieves a single command execution by its ID.
+// @Summary Get command execution by ID
+// @Description Retrieve a specific command execution, including its status history
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} db.CommandExecution
+// @Failure 404 {object} map[string]interface{}
+// @Router /executions/{id} [get]
+func (s *ExecutionService) getByID(c *gin.Context) {
+	id := c.Param("id")
+	execution, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch execution failed", "Fetch execution failed", http.StatusNotFound)
+		return
+	}
+	c.Set("response", execution)
+}
+
+// cancel cancels a pending or in-flight command execution.
+// @Summary Cancel command execution
+// @Description Remove a queued execution from the queue, or stop an in-flight one and ask the device to abort it
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} db.CommandExecution
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Execution already finished"
+// @Failure 500 {object} map[string]interface{}
+// @Router /executions/{id}/cancel [post]
+func (s *ExecutionService) cancel(c *gin.Context) {
+	id := c.Param("id")
+	execution, err := worker.CancelCommandExecution(id)
+	switch {
+	case errors.Is(err, worker.ErrExecutionNotFound):
+		utils.HandleHTTPError(c, "Cancel execution failed", "Fetch execution failed", http.StatusNotFound)
+		return
+	case errors.Is(err, worker.ErrExecutionNotCancellable):
+		utils.HandleHTTPError(c, "Cancel execution failed", "Execution already finished", http.StatusConflict)
+		return
+	case err != nil:
+		utils.HandleHTTPError(c, "Cancel execution failed: "+err.Error(), "Cancel execution failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", execution)
+}
+
+// reschedule moves a scheduled command execution to another time.
+// @Summary Reschedule command execution
+// @Description Change when a scheduled execution is dispatched, using executeAt or executeIn (seconds)
+// @Tags executions
+// @Accept json
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param schedule body models.RescheduleExecutionDTO true "New schedule"
+// @Success 200 {object} db.CommandExecution
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Execution is not scheduled anymore"
+// @Failure 500 {object} map[string]interface{}
+// @Router /executions/{id}/reschedule [post]
+func (s *ExecutionService) reschedule(c *gin.Context) {
+	id := c.Param("id")
+	dto := c.MustGet("Body").(models.RescheduleExecutionDTO)
+	now := time.Now()
+	at := dto.ProcessAt(now)
+	if at == nil || !at.After(now) {
+		utils.HandleHTTPError(c, "Reschedule execution failed", "executeAt must be in the future or executeIn set")
+		return
+	}
+
+	execution, err := worker.RescheduleCommandExecution(id, *at)
+	switch {
+	case errors.Is(err, worker.ErrExecutionNotFound):
+		utils.HandleHTTPError(c, "Reschedule execution failed", "Fetch execution failed", http.StatusNotFound)
+		return
+	case errors.Is(err, worker.ErrExecutionNotReschedulable):
+		utils.HandleHTTPError(c, "Reschedule execution failed", "Execution is not scheduled", http.StatusConflict)
+		return
+	case err != nil:
+		utils.HandleHTTPError(c, "Reschedule execution failed: "+err.Error(), "Reschedule execution failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", execution)
+}
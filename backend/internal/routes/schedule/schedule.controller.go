@@ -0,0 +1,21 @@
+package schedule
+
+import (
+	"command-dispatcher/internal/core/pipes"
+	"command-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register sets up the schedule routes within the provided Gin router group.
+func Register(r *gin.RouterGroup) {
+	route := r.Group("/schedules")
+
+	scheduleService := NewScheduleService()
+
+	route.POST("", pipes.Body[models.ScheduleCreateDTO], scheduleService.create)
+	route.GET("", scheduleService.getAll)
+	route.GET("/:id", scheduleService.getByID)
+	route.PATCH("/:id", pipes.Body[models.ScheduleUpdateDTO], scheduleService.update)
+	route.DELETE("/:id", scheduleService.delete)
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"command-dispatcher/internal/config/db"
+
+	"gorm.io/gorm"
+)
+
+type ScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(database *gorm.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: database}
+}
+
+func (r *ScheduleRepository) Create(schedule *db.CommandSchedule) error {
+	return r.db.Omit("CommandConfig").Create(schedule).Error
+}
+
+func (r *ScheduleRepository) FindAll() ([]db.CommandSchedule, error) {
+	var schedules []db.CommandSchedule
+	if err := r.db.Order("created_at").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) FindByID(id string) (*db.CommandSchedule, error) {
+	var schedule db.CommandSchedule
+	if err := r.db.First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *ScheduleRepository) Update(schedule *db.CommandSchedule) error {
+	return r.db.Omit("CommandConfig").Save(schedule).Error
+}
+
+func (r *ScheduleRepository) Delete(id string) error {
+	return r.db.Delete(&db.CommandSchedule{}, "id = ?", id).Error
+}
+
+// FindCommandConfig returns the command configuration a schedule dispatches.
+func (r *ScheduleRepository) FindCommandConfig(id string) (*db.CommandConfig, error) {
+	var config db.CommandConfig
+	if err := r.db.First(&config, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// DeviceGroupExists reports whether the device group a schedule targets exists.
+func (r *ScheduleRepository) DeviceGroupExists(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&db.DeviceGroup{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,230 @@
+package schedule
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/core/services/parameters"
+	"command-dispatcher/internal/models"
+	"command-dispatcher/internal/utils"
+	"command-dispatcher/internal/worker"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleService manages recurring command schedules.
+type ScheduleService struct {
+	repo *ScheduleRepository
+}
+
+// NewScheduleService creates a new ScheduleService instance.
+func NewScheduleService() *ScheduleService {
+	database := db.GetDB()
+	return &ScheduleService{repo: NewScheduleRepository(database)}
+}
+
+// create handles creating a new command schedule.
+// @Summary Create a command schedule
+// @Description Dispatch a command configuration to a device every time the cron expression fires in the given time zone.
+// @Description Set groupId or selector instead of deviceId to dispatch a batch to the devices of a group or matching a tag selector,
+// @Description resolved at every run.
+// @Description Schedules are picked up by the scheduler within 30 seconds. Runs must be at least 30 seconds apart, so @every 10s is rejected.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param schedule body models.ScheduleCreateDTO true "Command Schedule"
+// @Success 201 {object} db.CommandSchedule
+// @Failure 400 {object} map[string]interface{} "Invalid body, cron expression, time zone, interval, target, command config or parameters"
+// @Failure 500 {object} map[string]interface{}
+// @Router /schedules [post]
+func (s *ScheduleService) create(c *gin.Context) {
+	dto := c.MustGet("Body").(models.ScheduleCreateDTO)
+	schedule := dto.ToEntity()
+
+	if !s.checkSchedule(c, schedule) {
+		return
+	}
+
+	if err := s.repo.Create(schedule); err != nil {
+		utils.HandleHTTPError(c, "Create schedule failed: "+err.Error(), "Create schedule failed", http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(201)
+	c.Set("response", schedule)
+}
+
+// getAll retrieves all command schedules.
+// @Summary Get all command schedules
+// @Description Retrieve all command schedules with their last and next run
+// @Tags schedules
+// @Produce json
+// @Success 200 {array} db.CommandSchedule
+// @Failure 500 {object} map[string]interface{}
+// @Router /schedules [get]
+func (s *ScheduleService) getAll(c *gin.Context) {
+	schedules, err := s.repo.FindAll()
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch schedules failed", "Fetch schedules failed", http.StatusInternalServerError)
+		return
+	}
+	c.Status(200)
+	c.Set("response", schedules)
+}
+
+// getByID retrieves a single command schedule by its ID.
+// @Summary Get command schedule by ID
+// @Description Retrieve a specific command schedule with its last and next run
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} db.CommandSchedule
+// @Failure 404 {object} map[string]interface{}
+// @Router /schedules/{id} [get]
+func (s *ScheduleService) getByID(c *gin.Context) {
+	id := c.Param("id")
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch schedule failed", "Fetch schedule failed", http.StatusNotFound)
+		return
+	}
+	c.Set("response", schedule)
+}
+
+// update updates an existing command schedule.
+// @Summary Update command schedule
+// @Description Update an existing command schedule with partial data. Set enabled to false to pause it.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param schedule body models.ScheduleUpdateDTO true "Command Schedule Update"
+// @Success 200 {object} db.CommandSchedule
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /schedules/{id} [patch]
+func (s *ScheduleService) update(c *gin.Context) {
+	id := c.Param("id")
+	dto := c.MustGet("Body").(models.ScheduleUpdateDTO)
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		utils.HandleHTTPError(c, "Fetch schedule failed", "Fetch schedule failed", http.StatusNotFound)
+		return
+	}
+
+	dto.ApplyTo(schedule)
+	if !s.checkSchedule(c, schedule) {
+		return
+	}
+
+	if err := s.repo.Update(schedule); err != nil {
+		utils.HandleHTTPError(c, "Update schedule failed: "+err.Error(), "Update schedule failed", http.StatusInternalServerError)
+		return
+	}
+	c.Set("response", schedule)
+}
+
+// delete deletes a command schedule.
+// @Summary Delete command schedule
+// @Description Delete a command schedule by ID. Executions it already dispatched are kept.
+// @Tags schedules
+// @Param id path string true "Schedule ID"
+// @Success 204 "No Content"
+// @Failure 500 {object} map[string]interface{}
+// @Router /schedules/{id} [delete]
+func (s *ScheduleService) delete(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.repo.Delete(id); err != nil {
+		utils.HandleHTTPError(c, "Delete schedule failed", "Delete schedule failed", http.StatusInternalServerError)
+		return
+	}
+	c.Status(204)
+}
+
+// checkSchedule validates the cron expression, time zone, target, command config and parameters of the schedule,
+// and computes its next run.
+func (s *ScheduleService) checkSchedule(c *gin.Context, schedule *db.CommandSchedule) bool {
+	next, err := schedule.NextRun(time.Now())
+	if err != nil {
+		utils.HandleHTTPFieldErrors(c, "Invalid cron expression: "+err.Error(), "Invalid cron expression or time zone",
+			[]utils.FieldError{{Pointer: "/cronSpec", Detail: err.Error()}})
+		return false
+	}
+	// Successive runs of a cron expression are at least a minute apart, only @every can be shorter
+	if following, _ := schedule.NextRun(next); following.Sub(next) < worker.MinScheduleInterval {
+		detail := fmt.Sprintf("runs must be at least %s apart", worker.MinScheduleInterval)
+		utils.HandleHTTPFieldErrors(c, "Schedule interval too short", "Schedule interval too short",
+			[]utils.FieldError{{Pointer: "/cronSpec", Detail: detail}})
+		return false
+	}
+
+	if !s.checkTarget(c, schedule) {
+		return false
+	}
+
+	config, err := s.repo.FindCommandConfig(schedule.CommandConfigID)
+	if err != nil {
+		utils.HandleHTTPFieldErrors(c, "Fetch command config failed", "Unknown command config",
+			[]utils.FieldError{{Pointer: "/commandConfigId", Detail: "command config does not exist"}})
+		return false
+	}
+	fieldErrors, err := parameters.NewParametersService().Check(config.PayloadSchema, schedule.Parameters)
+	if err != nil {
+		utils.HandleHTTPError(c, "Validate parameters failed: "+err.Error(), "Invalid payload schema", http.StatusInternalServerError)
+		return false
+	}
+	if len(fieldErrors) > 0 {
+		utils.HandleHTTPFieldErrors(c, "Parameters do not match the payload schema", "Invalid parameters", fieldErrors)
+		return false
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	}
+	return true
+}
+
+// checkTarget validates the device, device group or tag selector the schedule targets.
+// The devices of a group or selector are only resolved when the schedule runs, so an empty group or selector is accepted.
+func (s *ScheduleService) checkTarget(c *gin.Context, schedule *db.CommandSchedule) bool {
+	if schedule.DeviceID == "" && schedule.GroupID == nil && schedule.Selector == "" {
+		utils.HandleHTTPFieldErrors(c, "Missing schedule target", "Missing target",
+			[]utils.FieldError{{Pointer: "/deviceId", Detail: "one of deviceId, groupId or selector is required"}})
+		return false
+	}
+	if schedule.DeviceID != "" {
+		unknown, err := worker.FindUnknownDevices([]string{schedule.DeviceID})
+		if err != nil {
+			utils.HandleHTTPError(c, "Fetch devices failed: "+err.Error(), "Fetch devices failed", http.StatusInternalServerError)
+			return false
+		}
+		if len(unknown) > 0 {
+			utils.HandleHTTPFieldErrors(c, "Unknown target devices", "Unknown device",
+				[]utils.FieldError{{Pointer: "/deviceId", Detail: "unknown device " + schedule.DeviceID}})
+			return false
+		}
+	}
+	if schedule.Selector != "" {
+		if _, err := models.ParseTagSelector(schedule.Selector); err != nil {
+			utils.HandleHTTPFieldErrors(c, "Invalid tag selector: "+err.Error(), "Invalid tag selector",
+				[]utils.FieldError{{Pointer: "/selector", Detail: err.Error()}})
+			return false
+		}
+	}
+	if schedule.GroupID != nil {
+		exists, err := s.repo.DeviceGroupExists(*schedule.GroupID)
+		if err != nil {
+			utils.HandleHTTPError(c, "Fetch device group failed: "+err.Error(), "Fetch device group failed", http.StatusInternalServerError)
+			return false
+		}
+		if !exists {
+			utils.HandleHTTPFieldErrors(c, "Fetch device group failed", "Unknown device group",
+				[]utils.FieldError{{Pointer: "/groupId", Detail: "device group does not exist"}})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeviceRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceRepository(database *gorm.DB) *DeviceRepository {
+	return &DeviceRepository{db: database}
+}
+
+// UpsertStatus records the status reported by a device, registering the device if it is not known yet.
+// Reported attributes are merged into the known ones; an empty firmware version keeps the known one.
+func (r *DeviceRepository) UpsertStatus(id, status, firmwareVersion string, attributes json.RawMessage, seenAt time.Time) error {
+	if len(attributes) == 0 {
+		attributes = json.RawMessage("{}")
+	}
+	device := db.Device{
+		ID:              id,
+		Status:          status,
+		LastSeenAt:      &seenAt,
+		FirmwareVersion: firmwareVersion,
+		Attributes:      attributes,
+		Tags:            map[string]string{},
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "status"}, Value: status},
+			{Column: clause.Column{Name: "last_seen_at"}, Value: seenAt},
+			{Column: clause.Column{Name: "updated_at"}, Value: seenAt},
+			{Column: clause.Column{Name: "firmware_version"}, Value: gorm.Expr("COALESCE(NULLIF(EXCLUDED.firmware_version, ''), devices.firmware_version)")},
+			{Column: clause.Column{Name: "attributes"}, Value: gorm.Expr("COALESCE(devices.attributes, '{}'::jsonb) || EXCLUDED.attributes")},
+		},
+	}).Create(&device).Error
+}
+
+// MarkNodeOffline records the death of a Sparkplug edge node and takes the devices behind it offline.
+// A bdSeq not matching the one of the last birth of the node means the death ends an earlier session:
+// nothing is updated and false is returned. Without a bdSeq the death is always applied.
+func (r *DeviceRepository) MarkNodeOffline(group, nodeID string, bdSeq *uint64, seenAt time.Time) (bool, error) {
+	applied := true
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		node := tx.Model(&db.Device{}).Where("id = ?", nodeID)
+		if bdSeq != nil {
+			node = node.Where("(attributes->'sparkplug'->>'bdSeq' IS NULL OR attributes->'sparkplug'->>'bdSeq' = ?)", strconv.FormatUint(*bdSeq, 10))
+		}
+		updates := map[string]any{"status": db.DeviceStatusOffline, "last_seen_at": seenAt, "updated_at": seenAt}
+		result := node.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Unknown edge nodes are registered, stale deaths of known ones are ignored
+			var count int64
+			if err := tx.Model(&db.Device{}).Where("id = ?", nodeID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				applied = false
+				return nil
+			}
+			if err := NewDeviceRepository(tx).UpsertStatus(nodeID, db.DeviceStatusOffline, "", nil, seenAt); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&db.Device{}).
+			Where("attributes->'sparkplug'->>'group' = ? AND attributes->'sparkplug'->>'node' = ?", group, nodeID).
+			Where("status <> ?", db.DeviceStatusOffline).
+			Updates(map[string]any{"status": db.DeviceStatusOffline, "updated_at": seenAt}).Error
+	})
+	return applied, err
+}
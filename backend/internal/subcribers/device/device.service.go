@@ -1 +1,153 @@
 package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/worker"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// statusReport is the payload devices publish on their status topic, see statusTopicConvention.
+// A plain "online" or "offline" payload, as typically used for birth and Last Will messages, is accepted as well.
+type statusReport struct {
+	Status          string          `json:"status"`
+	FirmwareVersion string          `json:"firmwareVersion"`
+	Attributes      json.RawMessage `json:"attributes"`
+}
+
+// Presence messages are applied by statusWorkers goroutines rather than in the MQTT callback, which would hold up
+// the messages of every other subscription meanwhile. Updates with the same order key go to the same worker
+// so they are applied in the order they were received. A full queue blocks the callback until a worker catches up.
+const (
+	statusWorkers   = 8
+	statusQueueSize = 256
+)
+
+// DeviceService keeps the device registry up to date from the messages devices publish.
+type DeviceService struct {
+	repo     *DeviceRepository
+	presence presenceConvention
+	queues   []chan presenceUpdate
+}
+
+var (
+	instance *DeviceService
+	once     sync.Once
+)
+
+// NewDeviceService returns the singleton DeviceService.
+func NewDeviceService() *DeviceService {
+	once.Do(func() {
+		presence, err := newPresenceConvention()
+		if err != nil {
+			log.Fatalf("Invalid presence configuration: %v", err)
+		}
+		instance = &DeviceService{repo: NewDeviceRepository(db.GetDB()), presence: presence}
+		instance.queues = make([]chan presenceUpdate, statusWorkers)
+		for i := range instance.queues {
+			instance.queues[i] = make(chan presenceUpdate, statusQueueSize)
+			go instance.applyStatuses(instance.queues[i])
+		}
+	})
+	return instance
+}
+
+// StatusTopics returns the topic filters presence messages are received on.
+func (s *DeviceService) StatusTopics() []string {
+	return s.presence.Topics()
+}
+
+// HandleStatus queues a presence message received on the given topic to be recorded.
+// It returns an error when the message cannot be parsed.
+func (s *DeviceService) HandleStatus(topic string, payload []byte) error {
+	update, err := s.presence.Parse(topic, payload)
+	if err != nil {
+		return err
+	}
+	h := fnv.New32a()
+	h.Write([]byte(update.orderKey))
+	s.queues[h.Sum32()%uint32(len(s.queues))] <- update
+	return nil
+}
+
+// applyStatuses records the presence updates of a queue, one at a time.
+func (s *DeviceService) applyStatuses(queue <-chan presenceUpdate) {
+	for update := range queue {
+		if err := s.applyStatus(update); err != nil {
+			log.Warnf("Could not record status of device %s: %v", update.deviceID, err)
+		}
+	}
+}
+
+// applyStatus records a presence update.
+// A device coming online gets the commands deferred while it was offline.
+// Every replica applies every update in the order the broker delivered them, so applying must be idempotent:
+// the registry ends up with the status of the last message whichever replica writes last, and deferred
+// executions are released by conditional status transitions.
+func (s *DeviceService) applyStatus(update presenceUpdate) error {
+	deviceID, report := update.deviceID, update.report
+	if update.nodeDeath != nil {
+		return s.handleNodeDeath(deviceID, update.nodeDeath)
+	}
+
+	if err := s.repo.UpsertStatus(deviceID, report.Status, report.FirmwareVersion, report.Attributes, time.Now()); err != nil {
+		return fmt.Errorf("update device %s: %w", deviceID, err)
+	}
+	log.Debugf("Device %s is %s", deviceID, report.Status)
+
+	if report.Status == db.DeviceStatusOnline {
+		worker.ReleaseDeferredExecutions(deviceID)
+	}
+	return nil
+}
+
+// handleNodeDeath takes a Sparkplug edge node and the devices behind it offline, unless the death certificate
+// is from an earlier session than the last birth of the node, which the broker may deliver after it.
+func (s *DeviceService) handleNodeDeath(nodeID string, death *nodeDeath) error {
+	applied, err := s.repo.MarkNodeOffline(death.group, nodeID, death.bdSeq, time.Now())
+	if err != nil {
+		return fmt.Errorf("update edge node %s: %w", nodeID, err)
+	}
+	if !applied {
+		log.Infof("Ignored death certificate of edge node %s from an earlier session (bdSeq %d)", nodeID, *death.bdSeq)
+		return nil
+	}
+	log.Debugf("Edge node %s and its devices are %s", nodeID, db.DeviceStatusOffline)
+	return nil
+}
+
+// parseStatusReport reads a status payload, normalizing the status to a DeviceStatus* constant.
+func parseStatusReport(payload []byte) (statusReport, error) {
+	var report statusReport
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(payload, &report); err != nil {
+			return report, fmt.Errorf("invalid status payload: %w", err)
+		}
+	} else {
+		report.Status = strings.Trim(trimmed, `"`)
+	}
+
+	switch strings.ToLower(report.Status) {
+	case "online":
+		report.Status = db.DeviceStatusOnline
+	case "offline":
+		report.Status = db.DeviceStatusOffline
+	default:
+		return report, fmt.Errorf("unknown status %q", report.Status)
+	}
+
+	if string(report.Attributes) == "null" {
+		report.Attributes = nil
+	}
+	if len(report.Attributes) > 0 && !strings.HasPrefix(strings.TrimSpace(string(report.Attributes)), "{") {
+		return report, fmt.Errorf("attributes must be a JSON object")
+	}
+	return report, nil
+}
@@ -0,0 +1,72 @@
+package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatusReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		expected  statusReport
+		expectErr bool
+	}{
+		{
+			name:     "Plain status",
+			payload:  "online",
+			expected: statusReport{Status: db.DeviceStatusOnline},
+		},
+		{
+			name:     "Quoted status, case insensitive",
+			payload:  ` "OFFLINE" `,
+			expected: statusReport{Status: db.DeviceStatusOffline},
+		},
+		{
+			name:    "Status report",
+			payload: `{"status":"online","firmwareVersion":"1.2.0","attributes":{"site":"hanoi"}}`,
+			expected: statusReport{
+				Status: db.DeviceStatusOnline, FirmwareVersion: "1.2.0", Attributes: []byte(`{"site":"hanoi"}`),
+			},
+		},
+		{
+			name:     "Null attributes",
+			payload:  `{"status":"offline","attributes":null}`,
+			expected: statusReport{Status: db.DeviceStatusOffline},
+		},
+		{
+			name:      "Attributes not an object",
+			payload:   `{"status":"online","attributes":[1]}`,
+			expectErr: true,
+		},
+		{
+			name:      "Unknown status",
+			payload:   "sleeping",
+			expectErr: true,
+		},
+		{
+			name:      "Missing status",
+			payload:   `{"firmwareVersion":"1.2.0"}`,
+			expectErr: true,
+		},
+		{
+			name:      "Invalid report",
+			payload:   `{"status":`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := parseStatusReport([]byte(tt.payload))
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, report)
+		})
+	}
+}
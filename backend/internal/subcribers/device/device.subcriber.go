@@ -11,9 +11,21 @@ import (
 func Register() {
 	log.Println("device subscriber registered")
 	mqttClient := _mqtt.GetClient()
+	deviceService := NewDeviceService()
 
-	// Use MQTT single-level wildcard `+`
-	mqttClient.Subscribe("devices/+/status", func(c mqtt.Client, m mqtt.Message) {
-		// TODO: dispatch to service, update DB, etc.
-	})
+	// Presence topics use the MQTT single-level wildcard `+` for the device ID.
+	// The subscription is not shared: a shared one could hand the Last Will and the next birth of a device
+	// to different replicas, which may record them out of order, and brokers do not deliver retained
+	// presence messages to shared subscriptions. Every replica records every status message instead,
+	// see DeviceService.applyStatus.
+	for _, topic := range deviceService.StatusTopics() {
+		err := mqttClient.Subscribe(topic, func(c mqtt.Client, m mqtt.Message) {
+			if err := deviceService.HandleStatus(m.Topic(), m.Payload()); err != nil {
+				log.Warnf("Could not handle device status on %s: %v", m.Topic(), err)
+			}
+		})
+		if err != nil {
+			log.Errorf("Could not subscribe to %s: %v", topic, err)
+		}
+	}
 }
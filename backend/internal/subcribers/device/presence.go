@@ -0,0 +1,255 @@
+package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Presence conventions, selected with the PRESENCE_CONVENTION environment variable.
+const (
+	// PresenceConventionStatus reads online/offline payloads on a per-device status topic,
+	// published as birth message on connect and as Last Will on disconnect.
+	PresenceConventionStatus = "status"
+	// PresenceConventionSparkplug reads Sparkplug B birth and death certificates (NBIRTH/NDEATH, DBIRTH/DDEATH).
+	PresenceConventionSparkplug = "sparkplug"
+)
+
+// defaultStatusTopic is the status topic pattern, unless overridden by the PRESENCE_STATUS_TOPIC environment variable.
+// {id} marks the level holding the device ID.
+const defaultStatusTopic = "devices/{id}/status"
+
+// presenceConvention maps the presence messages of devices to status reports.
+type presenceConvention interface {
+	// Topics returns the topic filters to subscribe to.
+	Topics() []string
+	// Parse returns the presence update carried by a message received on one of the topics.
+	Parse(topic string, payload []byte) (presenceUpdate, error)
+}
+
+// presenceUpdate is the status a device reported in a presence message.
+type presenceUpdate struct {
+	deviceID string
+	// orderKey groups the updates that must be applied in the order they were received
+	orderKey string
+	report   statusReport
+	// nodeDeath is set for the death certificate of a Sparkplug edge node, which takes its devices offline too
+	nodeDeath *nodeDeath
+}
+
+// nodeDeath identifies the session of a Sparkplug edge node that ended.
+type nodeDeath struct {
+	group string
+	// bdSeq matches the one of the birth certificate of the session, nil if the certificate did not carry it
+	bdSeq *uint64
+}
+
+// newPresenceConvention returns the presence convention configured by the environment.
+func newPresenceConvention() (presenceConvention, error) {
+	switch convention := os.Getenv("PRESENCE_CONVENTION"); convention {
+	case "", PresenceConventionStatus:
+		pattern := os.Getenv("PRESENCE_STATUS_TOPIC")
+		if pattern == "" {
+			pattern = defaultStatusTopic
+		}
+		return newStatusTopicConvention(pattern)
+	case PresenceConventionSparkplug:
+		return sparkplugConvention{}, nil
+	default:
+		return nil, fmt.Errorf("unknown presence convention %q", convention)
+	}
+}
+
+// statusTopicConvention reads status reports published on a topic pattern such as devices/{id}/status.
+type statusTopicConvention struct {
+	pattern []string
+	idLevel int
+}
+
+func newStatusTopicConvention(pattern string) (*statusTopicConvention, error) {
+	levels := strings.Split(pattern, "/")
+	idLevel := -1
+	for i, level := range levels {
+		switch {
+		case level == "{id}" && idLevel < 0:
+			idLevel = i
+		case strings.ContainsAny(level, "{}+#"):
+			return nil, fmt.Errorf("invalid status topic %q: only one {id} level and no wildcards are allowed", pattern)
+		}
+	}
+	if idLevel < 0 {
+		return nil, fmt.Errorf("invalid status topic %q: missing {id} level", pattern)
+	}
+	return &statusTopicConvention{pattern: levels, idLevel: idLevel}, nil
+}
+
+func (s *statusTopicConvention) Topics() []string {
+	levels := append([]string{}, s.pattern...)
+	levels[s.idLevel] = "+"
+	return []string{strings.Join(levels, "/")}
+}
+
+func (s *statusTopicConvention) Parse(topic string, payload []byte) (presenceUpdate, error) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(s.pattern) || levels[s.idLevel] == "" {
+		return presenceUpdate{}, fmt.Errorf("unexpected status topic %q", topic)
+	}
+	update := presenceUpdate{deviceID: levels[s.idLevel], orderKey: levels[s.idLevel]}
+	report, err := parseStatusReport(payload)
+	if err != nil {
+		return update, fmt.Errorf("device %s: %w", update.deviceID, err)
+	}
+	update.report = report
+	return update, nil
+}
+
+// sparkplugConvention reads Sparkplug B certificates: spBv1.0/{group}/NBIRTH|NDEATH/{edge node}
+// for edge nodes and spBv1.0/{group}/DBIRTH|DDEATH/{edge node}/{device} for the devices behind them.
+// The certificate type tells the presence. Edge nodes and devices record the group and node they belong to
+// in their sparkplug attribute, so the death of a node takes its devices offline. Node certificates carry
+// the bdSeq of their session, which tells apart the late death certificate of a previous session.
+type sparkplugConvention struct{}
+
+const (
+	sparkplugNamespace = "spBv1.0"
+	// sparkplugAttribute is the device attribute holding the Sparkplug identity of edge nodes and devices.
+	sparkplugAttribute = "sparkplug"
+	sparkplugBdSeq     = "bdSeq"
+)
+
+func (sparkplugConvention) Topics() []string {
+	return []string{
+		sparkplugNamespace + "/+/NBIRTH/+",
+		sparkplugNamespace + "/+/NDEATH/+",
+		sparkplugNamespace + "/+/DBIRTH/+/+",
+		sparkplugNamespace + "/+/DDEATH/+/+",
+	}
+}
+
+func (sparkplugConvention) Parse(topic string, payload []byte) (presenceUpdate, error) {
+	levels := strings.Split(topic, "/")
+	if len(levels) < 4 || levels[0] != sparkplugNamespace {
+		return presenceUpdate{}, fmt.Errorf("unexpected sparkplug topic %q", topic)
+	}
+
+	var update presenceUpdate
+	wantLevels := 4
+	switch levels[2] {
+	case "NBIRTH":
+		update.report.Status = db.DeviceStatusOnline
+	case "NDEATH":
+		update.report.Status = db.DeviceStatusOffline
+	case "DBIRTH":
+		update.report.Status, wantLevels = db.DeviceStatusOnline, 5
+	case "DDEATH":
+		update.report.Status, wantLevels = db.DeviceStatusOffline, 5
+	default:
+		return update, fmt.Errorf("unexpected sparkplug message type %q", levels[2])
+	}
+
+	// Edge nodes are identified by their node ID, devices by the last level of the topic
+	update.deviceID = levels[len(levels)-1]
+	if len(levels) != wantLevels || levels[1] == "" || update.deviceID == "" {
+		return presenceUpdate{}, fmt.Errorf("unexpected sparkplug topic %q", topic)
+	}
+	group, node := levels[1], levels[3]
+	// The death of a node applies to its devices, so all the certificates of a node are applied in order
+	update.orderKey = group + "/" + node
+
+	switch levels[2] {
+	case "NBIRTH":
+		bdSeq, err := parseSparkplugBdSeq(payload)
+		if err != nil {
+			return update, fmt.Errorf("edge node %s: %w", node, err)
+		}
+		identity := map[string]any{"group": group}
+		if bdSeq != nil {
+			identity[sparkplugBdSeq] = *bdSeq
+		}
+		update.report.Attributes = sparkplugAttributes(identity)
+	case "NDEATH":
+		bdSeq, err := parseSparkplugBdSeq(payload)
+		if err != nil {
+			return update, fmt.Errorf("edge node %s: %w", node, err)
+		}
+		update.nodeDeath = &nodeDeath{group: group, bdSeq: bdSeq}
+	case "DBIRTH":
+		update.report.Attributes = sparkplugAttributes(map[string]any{"group": group, "node": node})
+	}
+	return update, nil
+}
+
+// sparkplugAttributes returns the attributes recording the Sparkplug identity of an edge node or device.
+func sparkplugAttributes(identity map[string]any) json.RawMessage {
+	// Marshalling strings and integers cannot fail
+	b, _ := json.Marshal(map[string]any{sparkplugAttribute: identity})
+	return b
+}
+
+// parseSparkplugBdSeq returns the value of the bdSeq metric of a Sparkplug B payload, or nil if it has none.
+// Only the fields leading to the metric are decoded: Payload.metrics (2), Metric.name (1),
+// and the Metric.int_value (10) or Metric.long_value (11) holding the sequence number.
+func parseSparkplugBdSeq(payload []byte) (*uint64, error) {
+	for len(payload) > 0 {
+		num, typ, n := protowire.ConsumeTag(payload)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid sparkplug payload: %w", protowire.ParseError(n))
+		}
+		payload = payload[n:]
+		if num != 2 || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, payload); n < 0 {
+				return nil, fmt.Errorf("invalid sparkplug payload: %w", protowire.ParseError(n))
+			}
+			payload = payload[n:]
+			continue
+		}
+
+		metric, n := protowire.ConsumeBytes(payload)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid sparkplug payload: %w", protowire.ParseError(n))
+		}
+		payload = payload[n:]
+		name, value, err := parseSparkplugMetric(metric)
+		if err != nil {
+			return nil, err
+		}
+		if name == sparkplugBdSeq && value != nil {
+			return value, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseSparkplugMetric returns the name of a Sparkplug B metric and its integer value, if it has one.
+func parseSparkplugMetric(metric []byte) (string, *uint64, error) {
+	var name string
+	var value *uint64
+	for len(metric) > 0 {
+		num, typ, n := protowire.ConsumeTag(metric)
+		if n < 0 {
+			return "", nil, fmt.Errorf("invalid sparkplug metric: %w", protowire.ParseError(n))
+		}
+		metric = metric[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var b []byte
+			b, n = protowire.ConsumeBytes(metric)
+			name = string(b)
+		case (num == 10 || num == 11) && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(metric)
+			value = &v
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, metric)
+		}
+		if n < 0 {
+			return "", nil, fmt.Errorf("invalid sparkplug metric: %w", protowire.ParseError(n))
+		}
+		metric = metric[n:]
+	}
+	return name, value, nil
+}
@@ -0,0 +1,279 @@
+package device
+
+import (
+	"command-dispatcher/internal/config/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// sparkplugPayload encodes a Sparkplug B payload with a timestamp and the given integer metrics.
+func sparkplugPayload(metrics map[string]uint64) []byte {
+	b := protowire.AppendTag(nil, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1700000000000)
+	for name, value := range metrics {
+		var metric []byte
+		metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+		metric = protowire.AppendString(metric, name)
+		metric = protowire.AppendTag(metric, 4, protowire.VarintType) // datatype
+		metric = protowire.AppendVarint(metric, 8)
+		metric = protowire.AppendTag(metric, 11, protowire.VarintType)
+		metric = protowire.AppendVarint(metric, value)
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, metric)
+	}
+	b = protowire.AppendTag(b, 3, protowire.VarintType) // seq
+	return protowire.AppendVarint(b, 0)
+}
+
+func TestNewStatusTopicConvention(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		topics    []string
+		expectErr bool
+	}{
+		{name: "Default pattern", pattern: defaultStatusTopic, topics: []string{"devices/+/status"}},
+		{name: "ID at the end", pattern: "fleet/presence/{id}", topics: []string{"fleet/presence/+"}},
+		{name: "Missing ID", pattern: "devices/status", expectErr: true},
+		{name: "Two IDs", pattern: "{id}/{id}/status", expectErr: true},
+		{name: "Wildcard", pattern: "+/{id}/status", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			convention, err := newStatusTopicConvention(tt.pattern)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.topics, convention.Topics())
+		})
+	}
+}
+
+func TestStatusTopicConventionParse(t *testing.T) {
+	convention, err := newStatusTopicConvention(defaultStatusTopic)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		topic     string
+		payload   string
+		expected  presenceUpdate
+		expectErr bool
+	}{
+		{
+			name:     "Plain status",
+			topic:    "devices/d1/status",
+			payload:  "online",
+			expected: presenceUpdate{deviceID: "d1", orderKey: "d1", report: statusReport{Status: db.DeviceStatusOnline}},
+		},
+		{
+			name:    "Status report",
+			topic:   "devices/d1/status",
+			payload: `{"status":"online","firmwareVersion":"1.2.0","attributes":{"site":"hanoi"}}`,
+			expected: presenceUpdate{deviceID: "d1", orderKey: "d1", report: statusReport{
+				Status: db.DeviceStatusOnline, FirmwareVersion: "1.2.0", Attributes: []byte(`{"site":"hanoi"}`),
+			}},
+		},
+		{
+			name:      "Invalid report",
+			topic:     "devices/d1/status",
+			payload:   "sleeping",
+			expectErr: true,
+		},
+		{
+			name:      "Other topic",
+			topic:     "devices/d1/status/extra",
+			payload:   "online",
+			expectErr: true,
+		},
+		{
+			name:      "Missing device",
+			topic:     "devices//status",
+			payload:   "online",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := convention.Parse(tt.topic, []byte(tt.payload))
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, update)
+		})
+	}
+}
+
+func TestSparkplugConventionParse(t *testing.T) {
+	bdSeq := uint64(7)
+
+	tests := []struct {
+		name      string
+		topic     string
+		payload   []byte
+		expected  presenceUpdate
+		expectErr bool
+	}{
+		{
+			name:    "Node birth",
+			topic:   "spBv1.0/plant1/NBIRTH/edge1",
+			payload: sparkplugPayload(map[string]uint64{sparkplugBdSeq: bdSeq}),
+			expected: presenceUpdate{deviceID: "edge1", orderKey: "plant1/edge1", report: statusReport{
+				Status: db.DeviceStatusOnline, Attributes: []byte(`{"sparkplug":{"bdSeq":7,"group":"plant1"}}`),
+			}},
+		},
+		{
+			name:    "Node birth without bdSeq",
+			topic:   "spBv1.0/plant1/NBIRTH/edge1",
+			payload: sparkplugPayload(map[string]uint64{"Node Control/Rebirth": 0}),
+			expected: presenceUpdate{deviceID: "edge1", orderKey: "plant1/edge1", report: statusReport{
+				Status: db.DeviceStatusOnline, Attributes: []byte(`{"sparkplug":{"group":"plant1"}}`),
+			}},
+		},
+		{
+			name:    "Node death",
+			topic:   "spBv1.0/plant1/NDEATH/edge1",
+			payload: sparkplugPayload(map[string]uint64{sparkplugBdSeq: bdSeq}),
+			expected: presenceUpdate{
+				deviceID:  "edge1",
+				orderKey:  "plant1/edge1",
+				report:    statusReport{Status: db.DeviceStatusOffline},
+				nodeDeath: &nodeDeath{group: "plant1", bdSeq: &bdSeq},
+			},
+		},
+		{
+			name:    "Node death without payload",
+			topic:   "spBv1.0/plant1/NDEATH/edge1",
+			payload: nil,
+			expected: presenceUpdate{
+				deviceID:  "edge1",
+				orderKey:  "plant1/edge1",
+				report:    statusReport{Status: db.DeviceStatusOffline},
+				nodeDeath: &nodeDeath{group: "plant1"},
+			},
+		},
+		{
+			name:    "Device birth",
+			topic:   "spBv1.0/plant1/DBIRTH/edge1/pump3",
+			payload: sparkplugPayload(nil),
+			expected: presenceUpdate{deviceID: "pump3", orderKey: "plant1/edge1", report: statusReport{
+				Status: db.DeviceStatusOnline, Attributes: []byte(`{"sparkplug":{"group":"plant1","node":"edge1"}}`),
+			}},
+		},
+		{
+			name:     "Device death",
+			topic:    "spBv1.0/plant1/DDEATH/edge1/pump3",
+			payload:  sparkplugPayload(nil),
+			expected: presenceUpdate{deviceID: "pump3", orderKey: "plant1/edge1", report: statusReport{Status: db.DeviceStatusOffline}},
+		},
+		{
+			name:      "Invalid payload",
+			topic:     "spBv1.0/plant1/NDEATH/edge1",
+			payload:   []byte{0x12, 0x05, 0x0a},
+			expectErr: true,
+		},
+		{
+			name:      "Device certificate on a node topic",
+			topic:     "spBv1.0/plant1/DBIRTH/edge1",
+			expectErr: true,
+		},
+		{
+			name:      "Node certificate on a device topic",
+			topic:     "spBv1.0/plant1/NBIRTH/edge1/pump3",
+			expectErr: true,
+		},
+		{
+			name:      "Data message",
+			topic:     "spBv1.0/plant1/NDATA/edge1",
+			expectErr: true,
+		},
+		{
+			name:      "Other namespace",
+			topic:     "spAv1.0/plant1/NBIRTH/edge1",
+			expectErr: true,
+		},
+		{
+			name:      "Missing group",
+			topic:     "spBv1.0//NBIRTH/edge1",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := sparkplugConvention{}.Parse(tt.topic, tt.payload)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected.deviceID, update.deviceID)
+			assert.Equal(t, tt.expected.orderKey, update.orderKey)
+			assert.Equal(t, tt.expected.report.Status, update.report.Status)
+			if tt.expected.report.Attributes != nil {
+				assert.JSONEq(t, string(tt.expected.report.Attributes), string(update.report.Attributes))
+			} else {
+				assert.Nil(t, update.report.Attributes)
+			}
+			assert.Equal(t, tt.expected.nodeDeath, update.nodeDeath)
+		})
+	}
+}
+
+func TestParseSparkplugBdSeq(t *testing.T) {
+	// bdSeq carried as Metric.int_value rather than long_value
+	var intMetric []byte
+	intMetric = protowire.AppendTag(intMetric, 1, protowire.BytesType)
+	intMetric = protowire.AppendString(intMetric, sparkplugBdSeq)
+	intMetric = protowire.AppendTag(intMetric, 10, protowire.VarintType)
+	intMetric = protowire.AppendVarint(intMetric, 3)
+	intPayload := protowire.AppendTag(nil, 2, protowire.BytesType)
+	intPayload = protowire.AppendBytes(intPayload, intMetric)
+
+	// bdSeq without value, as a metric alias or a null metric would be
+	var nullMetric []byte
+	nullMetric = protowire.AppendTag(nullMetric, 1, protowire.BytesType)
+	nullMetric = protowire.AppendString(nullMetric, sparkplugBdSeq)
+	nullPayload := protowire.AppendTag(nil, 2, protowire.BytesType)
+	nullPayload = protowire.AppendBytes(nullPayload, nullMetric)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		expected  *uint64
+		expectErr bool
+	}{
+		{name: "Long value", payload: sparkplugPayload(map[string]uint64{sparkplugBdSeq: 255}), expected: ptr(uint64(255))},
+		{name: "Int value", payload: intPayload, expected: ptr(uint64(3))},
+		{name: "Among other metrics", payload: sparkplugPayload(map[string]uint64{"temperature": 21, sparkplugBdSeq: 0}), expected: ptr(uint64(0))},
+		{name: "Without value", payload: nullPayload, expected: nil},
+		{name: "Without bdSeq", payload: sparkplugPayload(map[string]uint64{"temperature": 21}), expected: nil},
+		{name: "Empty payload", payload: nil, expected: nil},
+		{name: "Truncated payload", payload: []byte{0x12, 0x05, 0x0a}, expectErr: true},
+		{name: "Truncated metric", payload: []byte{0x12, 0x02, 0x0a, 0x05}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bdSeq, err := parseSparkplugBdSeq(tt.payload)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, bdSeq)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -10,14 +10,29 @@ import (
 )
 
 type Page struct {
-	Size   int `json:"fromDate,omitempty" form:"page[size]" validate:"omitempty,gt=0"`
-	Number int `json:"toDate,omitempty" form:"page[number]" validate:"omitempty,gt=0"`
+	Size   int `json:"size,omitempty" form:"page[size]" validate:"omitempty,gt=0"`
+	Number int `json:"number,omitempty" form:"page[number]" validate:"omitempty,gt=0"`
 }
 
 type Pageable interface {
 	GetPage() Page
 }
 
+// FieldError describes an invalid field of the request.
+type FieldError struct {
+	Pointer string `json:"pointer"` // JSON pointer to the invalid field in the request document
+	Detail  string `json:"detail"`
+}
+
+type Sort struct {
+	Field string `json:"field,omitempty" form:"sort[field]" validate:"omitempty"`
+	Order string `json:"order,omitempty" form:"sort[order]" validate:"omitempty,oneof=asc desc"`
+}
+
+type Sortable interface {
+	GetSort() Sort
+}
+
 // HandleHTTPError handles HTTP errors by logging the error message, setting error details in the context, and aborting the request with a status code.
 //
 // Parameters:
@@ -36,6 +51,20 @@ func HandleHTTPError(c *gin.Context, errMsg string, details string, statusCode .
 	c.AbortWithStatus(code)
 }
 
+// HandleHTTPFieldErrors handles validation errors tied to specific fields of the request.
+// The field errors are reported as individual JSON:API errors alongside the main error.
+//
+// Parameters:
+// - c: *gin.Context - The Gin context for the current request.
+// - errMsg: string - The error message to be logged.
+// - details: string - Additional details about the error to be set in the context.
+// - fieldErrors: []FieldError - The invalid fields of the request.
+// - statusCode: ...int - Optional variadic parameter for the HTTP status code. If not provided, defaults to 400 (Bad Request).
+func HandleHTTPFieldErrors(c *gin.Context, errMsg string, details string, fieldErrors []FieldError, statusCode ...int) {
+	c.Set("fieldErrors", fieldErrors)
+	HandleHTTPError(c, errMsg, details, statusCode...)
+}
+
 // SetResponse sets the response data in the context.
 // Parameters:
 // - c: *gin.Context - The Gin context for the current request.
@@ -45,16 +74,28 @@ func SetResponse(c *gin.Context, data map[string]any) {
 	c.Set("response", gin.H(data))
 }
 
+// SetTotal sets the total number of matching records, reported in the JSON:API meta.
+// Parameters:
+// - c: *gin.Context - The Gin context for the current request.
+// - total: int64 - The total number of records matching the request, regardless of paging.
+func SetTotal(c *gin.Context, total int64) {
+	c.Set("total", total)
+}
+
 // CreatePaging creates a paging query for the provided GORM query and Pageable object.
 // Parameters:
 // - qr: *gorm.DB - The GORM query to be paginated.
 // - query: T - The Pageable object containing the paging information.
+// Returns the paginated query.
 // Example:
 //
 //	type GetSettingQueryDTO struct {
 //		Filter SettingFilterDTO `json:"filter"`
 //		Page   Page             `json:"page"`
-func CreatePaging[T Pageable](qr *gorm.DB, query T) {
+//	}
+//
+//	qr = CreatePaging(qr, query)
+func CreatePaging[T Pageable](qr *gorm.DB, query T) *gorm.DB {
 	page := query.GetPage()
 	if page.Size > 0 {
 		if page.Number > 0 {
@@ -64,4 +105,25 @@ func CreatePaging[T Pageable](qr *gorm.DB, query T) {
 			qr = qr.Limit(page.Size)
 		}
 	}
+	return qr
+}
+
+// CreateSorting orders the provided GORM query by the field requested in the Sortable object.
+// Parameters:
+// - qr: *gorm.DB - The GORM query to be sorted.
+// - query: T - The Sortable object containing the sort field and order.
+// - columns: map[string]string - The sortable fields mapped to their database columns. Unknown fields are ignored.
+// - fallback: string - The order clause used when no valid sort field is requested.
+// Returns the sorted query.
+func CreateSorting[T Sortable](qr *gorm.DB, query T, columns map[string]string, fallback string) *gorm.DB {
+	sort := query.GetSort()
+	column, ok := columns[sort.Field]
+	if !ok {
+		return qr.Order(fallback)
+	}
+	order := "asc"
+	if sort.Order == "desc" {
+		order = "desc"
+	}
+	return qr.Order(column + " " + order)
 }
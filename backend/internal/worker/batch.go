@@ -0,0 +1,241 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBatchNotFound       = errors.New("batch not found")
+	ErrBatchNotCancellable = errors.New("batch is not running or paused")
+	ErrBatchNotPaused      = errors.New("batch is not paused")
+	ErrBatchNotAbortable   = errors.New("batch is not rolling out")
+)
+
+// EnqueueCommandBatch records a batch for the devices and enqueues one execution of the command per device.
+// With a rollout policy only the first wave is enqueued, the rollout worker dispatches the following ones.
+// A device whose execution cannot be enqueued does not stop the others; its execution is marked FAILED.
+// Idempotency keys apply to the batch as a whole, see EnqueueCommandExecutionTask.
+func EnqueueCommandBatch(dto models.CommandCreateDTO, deviceIDs []string, opts DispatchOptions) (*db.CommandBatch, error) {
+	command, err := json.Marshal(dto)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch command: %w", err)
+	}
+
+	repo := NewBatchRepository(db.GetDB())
+	batch := &db.CommandBatch{
+		Description:     dto.Description,
+		CommandConfigID: dto.CommandConfigID,
+		DeviceIDs:       deviceIDs,
+		Status:          db.BatchStatusRunning,
+		Command:         command,
+		Selector:        opts.Selector,
+	}
+	if opts.GroupID != "" {
+		batch.GroupID = &opts.GroupID
+	}
+	if opts.Rollout != nil {
+		batch.Rollout = *opts.Rollout
+	}
+	if opts.IdempotencyKey != "" {
+		if original, err := findIdempotentBatch(repo, batch, opts.IdempotencyKey); original != nil || err != nil {
+			return original, err
+		}
+		batch.IdempotencyKey = &opts.IdempotencyKey
+	}
+	if err := repo.Create(batch); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) && opts.IdempotencyKey != "" {
+			// A concurrent request with the same key won the race
+			return findIdempotentBatch(repo, batch, opts.IdempotencyKey)
+		}
+		log.Errorf("Could not record command batch: %v", err)
+		return nil, err
+	}
+
+	if err := dispatchNextWave(batch, opts.ProcessAt); err != nil {
+		return nil, err
+	}
+	return GetCommandBatch(batch.ID)
+}
+
+// dispatchNextWave enqueues the executions of the wave following the current one,
+// and schedules the rollout check of the new wave.
+func dispatchNextWave(batch *db.CommandBatch, processAt *time.Time) error {
+	size := batch.NextWaveSize()
+	if size == 0 {
+		return nil
+	}
+	waveStart := batch.Dispatched
+	dispatched := waveStart + size
+
+	advanced, err := NewBatchRepository(db.GetDB()).AdvanceWave(batch.ID, batch.Wave, waveStart, dispatched)
+	if err != nil {
+		return err
+	}
+	if !advanced {
+		// Another rollout check or a resume already moved the batch on
+		return nil
+	}
+
+	var dto models.CommandCreateDTO
+	if err := json.Unmarshal(batch.Command, &dto); err != nil {
+		return fmt.Errorf("unmarshal batch command: %w", err)
+	}
+	opts := DispatchOptions{ProcessAt: processAt, BatchID: batch.ID}
+	for _, deviceID := range batch.DeviceIDs[waveStart:dispatched] {
+		command := dto
+		command.DeviceID = deviceID
+		if _, err := EnqueueCommandExecutionTask(command, opts); err != nil {
+			log.Errorf("Could not dispatch batch %s to device %s: %v", batch.ID, deviceID, err)
+		}
+	}
+	log.Infof("Batch %s dispatched wave %d to %d devices", batch.ID, batch.Wave+1, size)
+
+	if batch.Rollout.Enabled() {
+		checkAt := time.Now()
+		if processAt != nil && processAt.After(checkAt) {
+			checkAt = *processAt
+		}
+		return scheduleRolloutCheck(batch.ID, batch.Wave+1, checkAt.Add(rolloutCheckInterval))
+	}
+	return nil
+}
+
+// GetCommandBatch returns the batch along with the progress of its executions.
+func GetCommandBatch(id string) (*db.CommandBatch, error) {
+	repo := NewBatchRepository(db.GetDB())
+	batch, err := repo.FindByID(id)
+	if err != nil {
+		return nil, ErrBatchNotFound
+	}
+	progress, err := repo.Progress(id)
+	if err != nil {
+		return nil, err
+	}
+	batch.Progress = progress
+	return batch, nil
+}
+
+// CancelCommandBatch stops the rollout of the batch and cancels every execution that has not finished yet.
+func CancelCommandBatch(id string) (*db.CommandBatch, error) {
+	batch, err := GetCommandBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status == db.BatchStatusFinished {
+		return nil, ErrBatchNotCancellable
+	}
+
+	repo := NewBatchRepository(db.GetDB())
+	cancelled, err := repo.UpdateStatus(id, []string{db.BatchStatusRunning, db.BatchStatusPaused}, db.BatchStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrBatchNotCancellable
+	}
+	executionIDs, err := repo.FindUnfinishedExecutionIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, executionID := range executionIDs {
+		if _, err := CancelCommandExecution(executionID); err != nil && !errors.Is(err, ErrExecutionNotCancellable) {
+			log.Errorf("Could not cancel execution %s of batch %s: %v", executionID, id, err)
+		}
+	}
+	return GetCommandBatch(id)
+}
+
+// ResumeCommandBatch resumes a paused rollout by dispatching its next wave, whatever the outcome of the current one.
+func ResumeCommandBatch(id string) (*db.CommandBatch, error) {
+	batch, err := GetCommandBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != db.BatchStatusPaused {
+		return nil, ErrBatchNotPaused
+	}
+
+	if batch.NextWaveSize() == 0 {
+		// The paused wave was the last one, there is nothing left to dispatch
+		repo := NewBatchRepository(db.GetDB())
+		if _, err := repo.UpdateStatus(id, []string{db.BatchStatusPaused}, db.BatchStatusRunning); err != nil {
+			return nil, err
+		}
+		// Its executions may all have finished while the rollout was paused
+		if err := repo.Finish(id); err != nil {
+			return nil, err
+		}
+	} else if err := dispatchNextWave(batch, nil); err != nil {
+		return nil, err
+	}
+	return GetCommandBatch(id)
+}
+
+// AbortCommandBatch stops the rollout of the batch: devices of the following waves are never dispatched,
+// while executions already dispatched run to completion.
+func AbortCommandBatch(id string) (*db.CommandBatch, error) {
+	batch, err := GetCommandBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	if !batch.Rollout.Enabled() || batch.Status == db.BatchStatusFinished {
+		return nil, ErrBatchNotAbortable
+	}
+	aborted, err := NewBatchRepository(db.GetDB()).UpdateStatus(id, []string{db.BatchStatusRunning, db.BatchStatusPaused}, db.BatchStatusAborted)
+	if err != nil {
+		return nil, err
+	}
+	if !aborted {
+		return nil, ErrBatchNotAbortable
+	}
+	return GetCommandBatch(id)
+}
+
+// findIdempotentBatch returns the batch dispatched with the idempotency key within the idempotency window.
+// A key older than the window is released so it can be used again.
+// Keys are shared with single executions, see findIdempotentExecution.
+func findIdempotentBatch(repo *BatchRepository, batch *db.CommandBatch, key string) (*db.CommandBatch, error) {
+	original, err := repo.FindByIdempotencyKey(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		used, err := NewExecutionRepository(repo.db).HasIdempotencyKey(key, time.Now().Add(-idempotencyWindow()))
+		if used {
+			return nil, ErrIdempotencyKeyMismatch
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(original.CreatedAt) > idempotencyWindow() {
+		return nil, repo.ReleaseIdempotencyKey(original.ID)
+	}
+	if original.CommandConfigID != batch.CommandConfigID || !original.SameTarget(batch) {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+
+	replay, err := GetCommandBatch(original.ID)
+	if err != nil {
+		return nil, err
+	}
+	return replay, ErrIdempotentReplay
+}
+
+// scheduleRolloutCheck enqueues the check of the given wave of the batch.
+func scheduleRolloutCheck(batchID string, wave int, at time.Time) error {
+	t, err := rolloutWorker.Generate(batchID, wave)
+	if err != nil {
+		return err
+	}
+	_, err = EnqueueTask(t, asynq.ProcessAt(at))
+	return err
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BatchRepository struct {
+	db *gorm.DB
+}
+
+func NewBatchRepository(database *gorm.DB) *BatchRepository {
+	return &BatchRepository{db: database}
+}
+
+func (r *BatchRepository) Create(batch *db.CommandBatch) error {
+	return r.db.Create(batch).Error
+}
+
+func (r *BatchRepository) FindByID(id string) (*db.CommandBatch, error) {
+	var batch db.CommandBatch
+	if err := r.db.First(&batch, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// FindByIdempotencyKey returns the batch dispatched with the given idempotency key.
+func (r *BatchRepository) FindByIdempotencyKey(key string) (*db.CommandBatch, error) {
+	var batch db.CommandBatch
+	if err := r.db.First(&batch, "idempotency_key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// HasIdempotencyKey reports whether a batch created since the given time holds the idempotency key.
+func (r *BatchRepository) HasIdempotencyKey(key string, since time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&db.CommandBatch{}).Where("idempotency_key = ? AND created_at > ?", key, since).Count(&count).Error
+	return count > 0, err
+}
+
+// ReleaseIdempotencyKey frees the idempotency key of a batch so it can be used by a new dispatch.
+func (r *BatchRepository) ReleaseIdempotencyKey(id string) error {
+	return r.db.Model(&db.CommandBatch{}).Where("id = ?", id).Update("idempotency_key", nil).Error
+}
+
+// UpdateStatus moves the batch to the status if it is in one of the expected statuses,
+// and reports whether it did.
+func (r *BatchRepository) UpdateStatus(id string, from []string, status string) (bool, error) {
+	result := r.db.Model(&db.CommandBatch{}).Where("id = ? AND status IN ?", id, from).Update("status", status)
+	return result.RowsAffected > 0, result.Error
+}
+
+// AdvanceWave starts the next wave of the batch, whose devices are DeviceIDs[waveStart:dispatched].
+// It reports false when the batch left the given wave in the meantime.
+func (r *BatchRepository) AdvanceWave(id string, fromWave, waveStart, dispatched int) (bool, error) {
+	result := r.db.Model(&db.CommandBatch{}).
+		Where("id = ? AND wave = ? AND status IN ?", id, fromWave, []string{db.BatchStatusRunning, db.BatchStatusPaused}).
+		Updates(map[string]any{
+			"wave":         fromWave + 1,
+			"wave_start":   waveStart,
+			"dispatched":   dispatched,
+			"status":       db.BatchStatusRunning,
+			"pause_reason": "",
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Finish marks the batch FINISHED if it is running and the executions of all its devices reached a final status.
+func (r *BatchRepository) Finish(id string) error {
+	return r.finish(id)
+}
+
+// FinishWithExecution is Finish for the batch of the execution, if any.
+func (r *BatchRepository) FinishWithExecution(executionID string) error {
+	return r.finish(r.db.Model(&db.CommandExecution{}).Select("batch_id").Where("id = ?", executionID))
+}
+
+// finish is Finish for the batch ID given as a value or a subquery.
+func (r *BatchRepository) finish(id any) error {
+	finished := r.db.Model(&db.CommandExecution{}).
+		Select("count(*)").
+		Where("batch_id = command_batches.id AND status IN ?", db.FinalExecutionStatuses)
+	return r.db.Model(&db.CommandBatch{}).
+		Where("id = (?) AND status = ?", id, db.BatchStatusRunning).
+		Where("jsonb_array_length(device_ids) <= (?)", finished).
+		Update("status", db.BatchStatusFinished).Error
+}
+
+// Pause halts the rollout of the batch at the given wave.
+func (r *BatchRepository) Pause(id string, wave int, reason string) error {
+	return r.db.Model(&db.CommandBatch{}).
+		Where("id = ? AND wave = ? AND status = ?", id, wave, db.BatchStatusRunning).
+		Updates(map[string]any{"status": db.BatchStatusPaused, "pause_reason": reason}).Error
+}
+
+// FindUnfinishedExecutionIDs returns the executions of the batch that did not reach a final status yet.
+func (r *BatchRepository) FindUnfinishedExecutionIDs(id string) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&db.CommandExecution{}).
+		Where("batch_id = ? AND status NOT IN ?", id, db.FinalExecutionStatuses).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Progress counts the executions of the batch per status, optionally only those of the given devices.
+func (r *BatchRepository) Progress(id string, deviceIDs ...string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	qr := r.db.Model(&db.CommandExecution{}).Where("batch_id = ?", id)
+	if len(deviceIDs) > 0 {
+		qr = qr.Where("device_id IN ?", deviceIDs)
+	}
+	err := qr.Select("status, count(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	progress := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		progress[row.Status] = row.Count
+	}
+	return progress, nil
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFinishBatch(t *testing.T) {
+	database, pool := newRecordingDB(t)
+
+	assert.NoError(t, NewBatchRepository(database).Finish("b1"))
+	// The batch finishes once it is running and every one of its devices has a finished execution
+	if assert.Len(t, pool.statements, 1) {
+		assert.Equal(t, `UPDATE "command_batches" SET "status"=$1,"updated_at"=$2 `+
+			`WHERE (id = ($3) AND status = $4) AND jsonb_array_length(device_ids) <= `+
+			`(SELECT count(*) FROM "command_executions" WHERE batch_id = command_batches.id AND status IN ($5,$6,$7,$8,$9))`,
+			pool.statements[0])
+		assert.Equal(t, db.BatchStatusFinished, pool.args[0][0])
+		assert.Equal(t, []any{"b1", db.BatchStatusRunning}, pool.args[0][2:4])
+	}
+}
+
+func TestAdvanceWave(t *testing.T) {
+	database, pool := newRecordingDB(t)
+
+	advanced, err := NewBatchRepository(database).AdvanceWave("b1", 2, 10, 35)
+	assert.NoError(t, err)
+	assert.True(t, advanced)
+	// Only a batch still in the checked wave advances, resuming it if it was paused
+	if assert.Len(t, pool.statements, 1) {
+		assert.Contains(t, pool.statements[0], "WHERE id = $7 AND wave = $8 AND status IN ($9,$10)")
+		assert.Equal(t, []any{35, "", db.BatchStatusRunning, 3, 10}, pool.args[0][:5])
+		assert.Equal(t, []any{"b1", 2, db.BatchStatusRunning, db.BatchStatusPaused}, pool.args[0][6:])
+	}
+}
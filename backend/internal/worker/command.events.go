@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+// In event-driven mode, selected with WORKER_MODE=event, Process publishes the command and returns instead of
+// holding a worker slot while the device responds. The responses then advance the execution on whichever replica
+// receives them, and the SweepWorker times out the executions whose device did not respond before their deadline.
+// Every step is a conditional status transition, so the sweeper racing a response, or an acknowledgement
+// handled after the completion, settles the execution only once.
+const (
+	WorkerModeBlocking = "blocking"
+	WorkerModeEvent    = "event"
+)
+
+// isEventDriven reports whether commands are dispatched in event-driven mode.
+func isEventDriven() bool {
+	return os.Getenv("WORKER_MODE") == WorkerModeEvent
+}
+
+// responseDeadline returns when the device must have sent the next response an execution in the given status waits for.
+func responseDeadline(p models.CommandCreateDTO, status string) time.Time {
+	if status == db.ExecutionStatusSent && p.IsAcknowledgeRequired {
+		return time.Now().Add(acknowledgementTimeout(p))
+	}
+	return time.Now().Add(completionTimeout(p))
+}
+
+// dispatchEventDriven publishes the command and reports whether the execution now waits for the device to respond.
+// The execution is marked SENT before publishing, so a response received right away finds it waiting.
+func dispatchEventDriven(ctx context.Context, p models.CommandCreateDTO, taskId string) (bool, error) {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(taskId)
+	if err != nil {
+		return false, handleFailure(ctx, p, taskId, fmt.Errorf("find execution: %w", err))
+	}
+
+	// The task of a retried execution is a new one, so attempts are counted on the execution
+	n := execution.Attempt + 1
+	awaiting := p.IsAcknowledgeRequired || p.IsCompletionRequired
+	fields := map[string]any{"command_execution_time": time.Now(), "attempt": n}
+	if awaiting {
+		fields["event_driven"] = true
+		fields["response_deadline"] = responseDeadline(p, db.ExecutionStatusSent)
+	}
+	recordStatus(taskId, db.ExecutionStatusSent, fmt.Sprintf("attempt %d", n), fields)
+
+	if err := publishCommand(p, taskId); err != nil {
+		return false, handleFailure(ctx, p, taskId, err)
+	}
+	if !awaiting {
+		recordStatus(taskId, db.ExecutionStatusCompleted, "", map[string]any{"completed_at": time.Now()})
+	}
+	log.Infof("Published command for device %s, task %s", p.DeviceID, taskId)
+	return awaiting, nil
+}
+
+// advanceExecution applies a device response to the event-driven execution of the task.
+func advanceExecution(response deviceResponse) {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(response.TaskID)
+	if err != nil || !execution.AwaitsResponse() {
+		log.Warnf("Late or unknown %s from device %s for task %s, dropped", response.Kind, response.DeviceID, response.TaskID)
+		return
+	}
+	if execution.DeviceID != response.DeviceID {
+		log.Warnf("Dropped %s for task %s from device %s, the command was sent to device %s",
+			response.Kind, response.TaskID, response.DeviceID, execution.DeviceID)
+		return
+	}
+	p, err := executionPayload(execution)
+	if err != nil {
+		log.Errorf("Could not load the command of execution %s, its %s is dropped: %v", execution.ID, response.Kind, err)
+		return
+	}
+
+	switch response.Kind {
+	case responseAcknowledge:
+		acknowledgeExecution(execution, p, response.Payload)
+	case responseComplete:
+		if failure := parseDeviceFailure(response.Payload, false); failure != nil {
+			failExecution(execution, db.AwaitingExecutionStatuses, p, failure)
+			return
+		}
+		completeExecution(execution, p, response.Payload)
+	case responseFail:
+		failExecution(execution, db.AwaitingExecutionStatuses, p, parseDeviceFailure(response.Payload, true))
+	}
+}
+
+// acknowledgeExecution records the acknowledgement of the device and starts waiting for the completion, if required.
+func acknowledgeExecution(execution *db.CommandExecution, p models.CommandCreateDTO, payload []byte) {
+	if !p.IsAcknowledgeRequired {
+		log.Debugf("Ignored acknowledgement from device %s for task %s, the command does not require one", execution.DeviceID, execution.ID)
+		return
+	}
+	fields := map[string]any{"acknowledged_at": time.Now()}
+	if p.IsCompletionRequired {
+		fields["response_deadline"] = responseDeadline(p, db.ExecutionStatusAcknowledged)
+	}
+	event := db.ExecutionEvent{Status: db.ExecutionStatusAcknowledged, Payload: devicePayload(payload)}
+	if !transitionAwaiting(execution.ID, []string{db.ExecutionStatusSent}, event, fields) {
+		return
+	}
+	log.Infof("Command acknowledged for device %s, task %s", execution.DeviceID, execution.ID)
+
+	if !p.IsCompletionRequired {
+		event := db.ExecutionEvent{Status: db.ExecutionStatusCompleted}
+		fields := map[string]any{"completed_at": time.Now(), "response_deadline": nil}
+		if transitionAwaiting(execution.ID, []string{db.ExecutionStatusAcknowledged}, event, fields) {
+			releaseDevice(execution.DeviceID, execution.ID)
+		}
+	}
+}
+
+// completeExecution records the successful completion of the command.
+// The completion is accepted before the acknowledgement, which may still be handled concurrently.
+func completeExecution(execution *db.CommandExecution, p models.CommandCreateDTO, payload []byte) {
+	if !p.IsCompletionRequired {
+		log.Debugf("Ignored completion from device %s for task %s, the command does not require one", execution.DeviceID, execution.ID)
+		return
+	}
+	event := db.ExecutionEvent{Status: db.ExecutionStatusCompleted, Payload: devicePayload(payload)}
+	fields := map[string]any{"completed_at": time.Now(), "response_deadline": nil}
+	if !transitionAwaiting(execution.ID, db.AwaitingExecutionStatuses, event, fields) {
+		return
+	}
+	log.Infof("Command completed for device %s, task %s", execution.DeviceID, execution.ID)
+	releaseDevice(execution.DeviceID, execution.ID)
+}
+
+// failExecution records a failure or timeout of an event-driven execution still in one of the from statuses,
+// and schedules its requeue if the retry policy of the command allows another attempt, as handleFailure does
+// for a waiting worker.
+func failExecution(execution *db.CommandExecution, from []string, p models.CommandCreateDTO, err error) {
+	// The execution holds the attempt that failed, so Attempt-1 retries are used up
+	retry := isRetryable(p, err) && canRetry(p, execution.Attempt-1)
+
+	status := failureStatus(err)
+	fields := map[string]any{"error": err.Error(), "response_deadline": nil}
+	if retry {
+		status = db.ExecutionStatusRetrying
+	} else {
+		fields["completed_at"] = time.Now()
+	}
+	event := db.ExecutionEvent{Status: status, Message: err.Error()}
+	var deviceErr *deviceFailureError
+	if errors.As(err, &deviceErr) {
+		fields["error"] = deviceErr.reason
+		event = db.ExecutionEvent{Status: status, Payload: devicePayload(deviceErr.payload)}
+	}
+	if !transitionAwaiting(execution.ID, from, event, fields) {
+		return
+	}
+	log.Errorf("Command failed for device %s, task %s: %v", execution.DeviceID, execution.ID, err)
+
+	if !retry {
+		releaseDevice(execution.DeviceID, execution.ID)
+		return
+	}
+	if err := scheduleRequeue(execution, p); err != nil {
+		recordFailure(execution.ID, db.ExecutionStatusFailed, fmt.Errorf("requeue task: %w", err))
+		releaseDevice(execution.DeviceID, execution.ID)
+	}
+}
+
+// transitionAwaiting moves an execution waiting for its device to the status of the event, keeping the device
+// payload of the event as the execution result. It reports false if the execution was no longer in one of
+// the from statuses, e.g. because another response or the sweeper settled it first.
+func transitionAwaiting(id string, from []string, event db.ExecutionEvent, fields map[string]any) bool {
+	if event.Payload != nil {
+		fields["result"] = event.Payload
+	}
+	ok, err := NewExecutionRepository(db.GetDB()).TransitionStatus(id, from, event, fields)
+	if err != nil {
+		log.Errorf("Failed to update execution %s to %s: %v", id, event.Status, err)
+		return false
+	}
+	return ok
+}
+
+// scheduleRequeue has the RequeueWorker send the command again after the retry delay.
+// The device is kept meanwhile so later commands don't overtake the retry.
+func scheduleRequeue(execution *db.CommandExecution, p models.CommandCreateDTO) error {
+	t, err := requeueWorker.Generate(execution.ID)
+	if err != nil {
+		return err
+	}
+	_, err = EnqueueTask(t, asynq.ProcessIn(backoff(p, execution.Attempt-1)))
+	return err
+}
+
+// executionPayload returns the command an execution was dispatched with.
+// Executions recorded before the command was kept on them fall back to the payload of their task,
+// which asynq keeps for resultRetention once completed.
+func executionPayload(execution *db.CommandExecution) (models.CommandCreateDTO, error) {
+	var p models.CommandCreateDTO
+	command := execution.Command
+	if len(command) == 0 {
+		info, err := _queue.GetQueueInspector().GetTaskInfo(execution.Queue, execution.ID)
+		if err != nil {
+			return p, fmt.Errorf("get task: %w", err)
+		}
+		command = info.Payload
+	}
+	if err := json.Unmarshal(command, &p); err != nil {
+		return p, fmt.Errorf("unmarshal command: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseDeadline(t *testing.T) {
+	dto := models.CommandCreateDTO{IsAcknowledgeRequired: true, IsCompletionRequired: true, AcknowledgementTimeout: 5, CompletionTimeout: 30}
+
+	tests := []struct {
+		name     string
+		dto      models.CommandCreateDTO
+		status   string
+		expected time.Duration
+	}{
+		{name: "Waiting for the acknowledgement", dto: dto, status: db.ExecutionStatusSent, expected: 5 * time.Second},
+		{name: "Waiting for the completion", dto: dto, status: db.ExecutionStatusAcknowledged, expected: 30 * time.Second},
+		{
+			name:     "Sent without acknowledgement",
+			dto:      models.CommandCreateDTO{IsCompletionRequired: true, AcknowledgementTimeout: 5, CompletionTimeout: 30},
+			status:   db.ExecutionStatusSent,
+			expected: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			deadline := responseDeadline(tt.dto, tt.status)
+			assert.WithinRange(t, deadline, before.Add(tt.expected), time.Now().Add(tt.expected))
+		})
+	}
+}
+
+func TestAcknowledgeExecution(t *testing.T) {
+	database, pool := newRecordingDB(t)
+	handler := db.Handler
+	db.Handler = database
+	t.Cleanup(func() { db.Handler = handler })
+
+	execution := &db.CommandExecution{DeviceID: "d1", Status: db.ExecutionStatusSent, EventDriven: true}
+	execution.ID = "e1"
+
+	// An acknowledgement the command does not wait for is ignored
+	acknowledgeExecution(execution, models.CommandCreateDTO{IsCompletionRequired: true}, []byte(`{"accepted":true}`))
+	assert.Empty(t, pool.statements)
+
+	// Otherwise a sent execution moves on to wait for its completion, keeping the payload as result
+	p := models.CommandCreateDTO{IsAcknowledgeRequired: true, IsCompletionRequired: true}
+	acknowledgeExecution(execution, p, []byte(`{"accepted":true}`))
+	if assert.Len(t, pool.statements, 1) {
+		assert.Contains(t, pool.statements[0], `"acknowledged_at"=$`)
+		assert.Contains(t, pool.statements[0], `"response_deadline"=$`)
+		assert.Contains(t, pool.statements[0], `"result"=$`)
+		assert.Contains(t, pool.statements[0], "WHERE id = $")
+		assert.Contains(t, pool.statements[0], "AND status IN ($")
+		assert.Contains(t, pool.args[0], json.RawMessage(`{"accepted":true}`))
+		assert.Contains(t, pool.args[0], db.ExecutionStatusAcknowledged)
+		assert.Equal(t, db.ExecutionStatusSent, pool.args[0][len(pool.args[0])-1])
+	}
+}
+
+func TestExecutionPayload(t *testing.T) {
+	command := models.CommandCreateDTO{DeviceID: "d1", Type: "reboot", IsCompletionRequired: true, CompletionTimeout: 30}
+	b, _ := json.Marshal(command)
+
+	p, err := executionPayload(&db.CommandExecution{Command: b})
+	assert.NoError(t, err)
+	assert.Equal(t, command, p)
+}
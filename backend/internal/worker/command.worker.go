@@ -2,18 +2,52 @@ package worker
 
 import (
 	"command-dispatcher/internal/config/_mqtt"
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/core/services/parameters"
 	"command-dispatcher/internal/models"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/hibiken/asynq"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	defaultAcknowledgementTimeout = 60 * time.Second
+	defaultCompletionTimeout      = 60 * time.Second
+	// taskTimeoutMargin leaves room for publishing and status updates on top of the device timeouts.
+	taskTimeoutMargin = 10 * time.Second
+	// resultRetention keeps completed tasks, and the result written by the worker, in the queue.
+	resultRetention = 24 * time.Hour
+)
+
+var (
+	errAcknowledgementTimeout = errors.New("command acknowledgment timed out")
+	errCompletionTimeout      = errors.New("command completion timed out")
+)
+
+// deviceFailureError is returned when the device reports that it could not execute the command.
+type deviceFailureError struct {
+	reason  string
+	payload []byte
+}
+
+func (e *deviceFailureError) Error() string {
+	return "device reported failure: " + e.reason
+}
+
+// deviceReport is the optional structure of the payloads devices send back.
+// A completion payload whose status is "failed" or "error" is treated as a device failure.
+type deviceReport struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+}
+
 type CommandWorker struct {
 	jobName string
 }
@@ -39,102 +73,383 @@ func (cw *CommandWorker) Generate(dto models.CommandCreateDTO) (*asynq.Task, err
 	if err != nil {
 		return nil, fmt.Errorf("marshal command execution payload: %w", err)
 	}
-	log.Debugf("Generate command execution task type=%s deviceId=%s cmdType=%s", cw.jobName, dto.DeviceID, dto.Type)
-	return asynq.NewTask(cw.jobName, b, asynq.MaxRetry(-1), asynq.Timeout(30*time.Second)), nil
+	timeout := taskTimeout(dto)
+	maxRetry := dto.MaxRetries
+	if !dto.AllowConcurrent {
+		// Waiting behind another command is retried without counting as a failure, but asynq archives
+		// a task erroring once its retries are used up. The spare retry keeps a waiting task alive,
+		// while handleFailure still stops after MaxRetries failures.
+		maxRetry++
+	}
+	log.Debugf("Generate command execution task type=%s deviceId=%s cmdType=%s priority=%s timeout=%s", cw.jobName, dto.DeviceID, dto.Type, dto.Priority, timeout)
+	return asynq.NewTask(cw.jobName, b, asynq.Queue(priorityQueue(dto.Priority)), asynq.MaxRetry(maxRetry), asynq.Timeout(timeout), asynq.Retention(resultRetention)), nil
 }
 
 // Process executes the queued command.
-func (*CommandWorker) Process(ctx context.Context, t *asynq.Task) error {
+// The task ID is the ID of the CommandExecution row, whose status is updated at every step.
+// Waiting for the device stops when the task is cancelled, its deadline expires or the worker shuts down,
+// see handleInterruption.
+func (*CommandWorker) Process(taskCtx context.Context, t *asynq.Task) (err error) {
 	var p models.CommandCreateDTO
+	taskId := t.ResultWriter().TaskID()
+
+	ctx, stopWaiting := interruptible(taskCtx)
+	defer stopWaiting()
+	// Runs last, once the response route is released and the device kept for the requeued task
+	defer func() {
+		if errors.Is(err, errShuttingDown) {
+			awaitRequeue(taskCtx, taskId)
+		}
+	}()
 
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		recordFailure(taskId, db.ExecutionStatusFailed, err)
 		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
 	}
 
+	// The execution may have been cancelled while the task was waiting in the queue
+	if isCancelled(taskId) {
+		return fmt.Errorf("execution %s was cancelled: %w", taskId, asynq.SkipRetry)
+	}
+
+	// The deadline of the previous attempt expired with no retries left, but asynq retries it anyway
+	if isInterrupted(taskId) {
+		return fmt.Errorf("execution %s was interrupted: %w", taskId, asynq.SkipRetry)
+	}
+
+	// A deferred execution is released as soon as its device comes online, so still being deferred means the TTL expired
+	if isDeferred(taskId) {
+		recordFailure(taskId, db.ExecutionStatusFailed, errDeferExpired)
+		return fmt.Errorf("execution %s: %v: %w", taskId, errDeferExpired, asynq.SkipRetry)
+	}
+
+	// The device may have gone offline since the command was scheduled
+	if err := applyScheduledOfflinePolicy(taskId, p); err != nil {
+		return err
+	}
+
+	// The schema may have changed since the command was enqueued, so validate again before publishing
+	if err := validateParameters(p); err != nil {
+		recordFailure(taskId, db.ExecutionStatusFailed, err)
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	// Run one command at a time per device, unless the command opts out
+	var awaiting bool
+	if !p.AllowConcurrent {
+		acquired, head, lockErr := acquireDevice(ctx, p.DeviceID, taskId)
+		if lockErr != nil {
+			return handleFailure(ctx, p, taskId, lockErr)
+		}
+		if !acquired {
+			recordQueuedBehind(taskId, head)
+			return fmt.Errorf("%w: device %s, task %s", errDeviceBusy, p.DeviceID, taskId)
+		}
+		defer func() {
+			// Keep the device while the command will be retried so later commands don't overtake it,
+			// and while an event-driven command waits for the device to respond
+			if (err == nil && !awaiting) || errors.Is(err, asynq.SkipRetry) {
+				releaseDevice(p.DeviceID, taskId)
+			}
+		}()
+	}
+
 	// Publish the original task payload to MQTT (device-specific topic)
 	if !_mqtt.IsInitialized() {
-		return fmt.Errorf("mqtt client not initialized")
+		return handleFailure(ctx, p, taskId, errors.New("mqtt client not initialized"))
+	}
+
+	if isEventDriven() {
+		awaiting, err = dispatchEventDriven(ctx, p, taskId)
+		return err
+	}
+
+	// Don't send a command that would be sent again once the task is requeued
+	if ctx.Err() != nil {
+		return handleFailure(ctx, p, taskId, ctx.Err())
 	}
-	taskId := t.ResultWriter().TaskID()
 
 	log.Infof("Executing command for device %s, task %s", p.DeviceID, taskId)
 
-	publishCommand(p.DeviceID, taskId, t.Payload())
+	// Route the responses of the device before publishing so an immediate response is not missed
+	route := responses.register(p.DeviceID, taskId, taskTimeout(p))
+	defer responses.unregister(route)
 
-	if err := waitForAcknowledgement(ctx, p.DeviceID, taskId); err != nil {
-		return err
+	if err := publishCommand(p, taskId); err != nil {
+		return handleFailure(ctx, p, taskId, err)
 	}
+	recordStatus(taskId, db.ExecutionStatusSent, fmt.Sprintf("attempt %d", attempt(ctx)),
+		map[string]any{"command_execution_time": time.Now(), "attempt": attempt(ctx)})
 
-	if err := waitForCompletion(ctx, p.DeviceID, taskId); err != nil {
-		return err
+	// Fire-and-forget commands are complete as soon as they are published
+	var result []byte
+	if p.IsAcknowledgeRequired {
+		ackPayload, err := waitForAcknowledgement(ctx, route, acknowledgementTimeout(p))
+		if err != nil {
+			return handleFailure(ctx, p, taskId, err)
+		}
+		result = ackPayload
+		recordDeviceResponse(taskId, db.ExecutionStatusAcknowledged, ackPayload, map[string]any{"acknowledged_at": time.Now()})
+	}
+
+	if p.IsCompletionRequired {
+		completePayload, err := waitForCompletion(ctx, route, completionTimeout(p))
+		if err != nil {
+			return handleFailure(ctx, p, taskId, err)
+		}
+		result = completePayload
+		recordDeviceResponse(taskId, db.ExecutionStatusCompleted, completePayload, map[string]any{"completed_at": time.Now()})
+	} else {
+		recordStatus(taskId, db.ExecutionStatusCompleted, "", map[string]any{"completed_at": time.Now()})
+	}
+
+	if len(result) > 0 {
+		if _, err := t.ResultWriter().Write(result); err != nil {
+			log.Errorf("Failed to write result for device %s, task %s: %v", p.DeviceID, taskId, err)
+		}
 	}
 
 	log.Infof("Finished processing command for device %s, task %s", p.DeviceID, taskId)
 	return nil
 }
 
-// publishCommand publishes the command payload to the specified dispatch topic.
-func publishCommand(deviceID, taskId string, originalPayload []byte) {
-	dispatchTopic := fmt.Sprintf("device/%s/dispatch", deviceID)
+// validateParameters checks the command parameters against the payload schema of its command config.
+func validateParameters(dto models.CommandCreateDTO) error {
+	if dto.CommandConfigID == "" {
+		return nil
+	}
+	config, err := NewExecutionRepository(db.GetDB()).FindCommandConfig(dto.CommandConfigID)
+	if err != nil {
+		return fmt.Errorf("find command config %s: %w", dto.CommandConfigID, err)
+	}
 
-	var originalPayloadDTO models.CommandCreateDTO
-	if err := json.Unmarshal(originalPayload, &originalPayloadDTO); err != nil {
-		log.Errorf("Failed to unmarshal original command payload for device %s, task %s: %v", deviceID, taskId, err)
-		return // Or handle error appropriately
+	fieldErrors, err := parameters.NewParametersService().Check(config.PayloadSchema, dto.Parameters)
+	if err != nil {
+		return fmt.Errorf("validate parameters: %w", err)
 	}
+	if len(fieldErrors) > 0 {
+		messages := make([]string, len(fieldErrors))
+		for i, fieldError := range fieldErrors {
+			messages[i] = fieldError.Pointer + ": " + fieldError.Detail
+		}
+		return fmt.Errorf("parameters do not match the payload schema: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// publishCommand publishes the command payload, enriched with the task ID, to the device dispatch topic.
+func publishCommand(dto models.CommandCreateDTO, taskId string) error {
+	dispatchTopic := fmt.Sprintf("device/%s/dispatch", dto.DeviceID)
 
 	payloadWithTaskID := commandPayload{
-		CommandCreateDTO: originalPayloadDTO,
+		CommandCreateDTO: dto,
 		TaskID:           taskId,
 	}
 
 	finalPayload, err := json.Marshal(payloadWithTaskID)
 	if err != nil {
-		log.Errorf("Failed to marshal final command payload for device %s, task %s: %v", deviceID, taskId, err)
-		return // Or handle error appropriately
+		log.Errorf("Failed to marshal final command payload for device %s, task %s: %v", dto.DeviceID, taskId, err)
+		return err
 	}
 
-	_mqtt.GetClient().Publish(dispatchTopic, 2, false, finalPayload)
+	return _mqtt.GetClient().Publish(dispatchTopic, 2, false, finalPayload)
 }
 
-// waitForAcknowledgement waits for an acknowledgment from the device or times out.
-func waitForAcknowledgement(ctx context.Context, deviceID, taskId string) error {
-	acknowledgeTopic := fmt.Sprintf("device/%s/acknowledge/%s", deviceID, taskId)
-	ackCh := make(chan struct{})
+// recordStatus persists a status transition of the execution, logging instead of failing the task on error.
+func recordStatus(executionID, status, message string, fields map[string]any) {
+	recordEvent(executionID, db.ExecutionEvent{Status: status, Message: message}, fields)
+}
+
+// recordDeviceResponse persists a status transition reported by the device, keeping its payload
+// in the history and as the execution result.
+func recordDeviceResponse(executionID, status string, payload []byte, fields map[string]any) {
+	data := devicePayload(payload)
+	if data != nil {
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		fields["result"] = data
+	}
+	recordEvent(executionID, db.ExecutionEvent{Status: status, Payload: data}, fields)
+}
 
-	_mqtt.GetClient().Subscribe(acknowledgeTopic, func(client mqtt.Client, msg mqtt.Message) {
-		ackCh <- struct{}{}
-	}, 2)
-	defer _mqtt.GetClient().Unsubscribe(acknowledgeTopic) // Ensure unsubscribe happens
+func recordEvent(executionID string, event db.ExecutionEvent, fields map[string]any) {
+	if err := NewExecutionRepository(db.GetDB()).UpdateStatus(executionID, event, fields); err != nil {
+		log.Errorf("Failed to update execution %s to %s: %v", executionID, event.Status, err)
+	}
+}
 
-	select {
-	case <-ackCh:
-		log.Infof("Command aknowledged for device %s, task %s", deviceID, taskId)
+// devicePayload converts a device message payload to JSON. Payloads that are not JSON are kept as a JSON string.
+func devicePayload(payload []byte) json.RawMessage {
+	if len(payload) == 0 {
 		return nil
-	case <-time.After(20 * time.Second):
-		msg := fmt.Sprintf("Command aknowledgment timed out by device %s, task %s", deviceID, taskId)
-		log.Error(msg)
-		return errors.New(msg)
 	}
+	if json.Valid(payload) {
+		return payload
+	}
+	data, _ := json.Marshal(string(payload))
+	return data
 }
 
-// waitForCompletion waits for command completion from the device or times out.
-func waitForCompletion(ctx context.Context, deviceID, taskId string) error {
-	completeTopic := fmt.Sprintf("device/%s/complete/%s", deviceID, taskId)
-	completeCh := make(chan struct{})
+// recordFailure persists a terminal failure of the execution along with its reason.
+func recordFailure(executionID, status string, cause error) {
+	recordStatus(executionID, status, cause.Error(), map[string]any{"error": cause.Error(), "completed_at": time.Now()})
+}
 
-	_mqtt.GetClient().Subscribe(completeTopic, func(client mqtt.Client, msg mqtt.Message) {
-		completeCh <- struct{}{}
-	}, 2)
-	defer _mqtt.GetClient().Unsubscribe(completeTopic) // Ensure unsubscribe happens
+// acknowledgementTimeout returns how long to wait for the device to acknowledge the command.
+func acknowledgementTimeout(dto models.CommandCreateDTO) time.Duration {
+	if dto.AcknowledgementTimeout <= 0 {
+		return defaultAcknowledgementTimeout
+	}
+	return time.Duration(dto.AcknowledgementTimeout) * time.Second
+}
 
-	select {
-	case <-completeCh:
-		log.Infof("Command completed for device %s, task %s", deviceID, taskId)
+// completionTimeout returns how long to wait for the device to complete the command once acknowledged.
+func completionTimeout(dto models.CommandCreateDTO) time.Duration {
+	if dto.CompletionTimeout <= 0 {
+		return defaultCompletionTimeout
+	}
+	return time.Duration(dto.CompletionTimeout) * time.Second
+}
+
+// taskTimeout returns the asynq task timeout, covering every phase the command waits on.
+func taskTimeout(dto models.CommandCreateDTO) time.Duration {
+	timeout := taskTimeoutMargin
+	if dto.IsAcknowledgeRequired {
+		timeout += acknowledgementTimeout(dto)
+	}
+	if dto.IsCompletionRequired {
+		timeout += completionTimeout(dto)
+	}
+	return timeout
+}
+
+// failureStatus maps an error returned by the wait functions to the execution status it results in.
+func failureStatus(err error) string {
+	if errors.Is(err, errAcknowledgementTimeout) || errors.Is(err, errCompletionTimeout) {
+		return db.ExecutionStatusTimedOut
+	}
+	return db.ExecutionStatusFailed
+}
+
+// handleFailure records why the attempt failed and returns the error for asynq.
+// Failures the retry policy of the command does not cover skip the remaining retries.
+func handleFailure(ctx context.Context, p models.CommandCreateDTO, taskId string, err error) error {
+	if errors.Is(err, context.Canceled) && isCancelled(taskId) {
+		log.Infof("Command cancelled for device %s, task %s", p.DeviceID, taskId)
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	// Processing stopped, the attempt itself did not fail
+	if ctx.Err() != nil {
+		return handleInterruption(ctx, p, taskId)
+	}
+	retryable := isRetryable(p, err)
+
+	status := failureStatus(err)
+	fields := map[string]any{"error": err.Error()}
+	retriesLeft := hasRetriesLeft(ctx, p)
+	if retryable && retriesLeft {
+		status = db.ExecutionStatusRetrying
+	} else {
+		fields["completed_at"] = time.Now()
+	}
+
+	var deviceErr *deviceFailureError
+	if errors.As(err, &deviceErr) {
+		fields["error"] = deviceErr.reason
+		recordDeviceResponse(taskId, status, deviceErr.payload, fields)
+	} else {
+		recordEvent(taskId, db.ExecutionEvent{Status: status, Message: err.Error()}, fields)
+	}
+
+	if !retryable || !retriesLeft {
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	return err
+}
+
+// parseDeviceFailure returns the failure reported in a device payload, or nil if the payload reports success.
+// Payloads received on the fail topic are always failures, whatever their content.
+func parseDeviceFailure(payload []byte, onFailTopic bool) *deviceFailureError {
+	var report deviceReport
+	_ = json.Unmarshal(payload, &report)
+
+	failed := onFailTopic || strings.EqualFold(report.Status, "failed") || strings.EqualFold(report.Status, "error")
+	if !failed {
 		return nil
-	case <-time.After(5 * time.Second): // TODO: make timeout configurable
-		msg := fmt.Sprintf("Command completion timed out by device %s, task %s", deviceID, taskId)
-		log.Error(msg)
-		return errors.New(msg)
+	}
+
+	reason := report.Reason
+	if reason == "" {
+		reason = report.Error
+	}
+	if reason == "" && onFailTopic && !json.Valid(payload) {
+		reason = string(payload)
+	}
+	if reason == "" {
+		reason = "no reason given"
+	}
+	return &deviceFailureError{reason: reason, payload: payload}
+}
+
+// isCancelled reports whether the execution was cancelled by an operator.
+func isCancelled(executionID string) bool {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(executionID)
+	return err == nil && execution.Status == db.ExecutionStatusCancelled
+}
+
+// publishCancel asks the device to abort the command of the given task.
+func publishCancel(deviceID, taskId string) error {
+	cancelTopic := fmt.Sprintf("device/%s/cancel/%s", deviceID, taskId)
+	payload, err := json.Marshal(map[string]string{"taskId": taskId})
+	if err != nil {
+		return err
+	}
+	return _mqtt.GetClient().Publish(cancelTopic, 2, false, payload)
+}
+
+// waitForAcknowledgement waits for an acknowledgment from the device or times out.
+// It returns the payload of the acknowledgement message, or the error of the context once it is done.
+func waitForAcknowledgement(ctx context.Context, route *responseRoute, timeout time.Duration) ([]byte, error) {
+	select {
+	case payload := <-route.acknowledge:
+		log.Infof("Command aknowledged for device %s, task %s", route.deviceID, route.taskID)
+		return payload, nil
+	case payload := <-route.fail:
+		failure := parseDeviceFailure(payload, true)
+		log.Errorf("Command failed on device %s, task %s: %s", route.deviceID, route.taskID, failure.reason)
+		return nil, failure
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(timeout):
+		err := fmt.Errorf("%w by device %s, task %s", errAcknowledgementTimeout, route.deviceID, route.taskID)
+		log.Error(err)
+		return nil, err
+	}
+}
+
+// waitForCompletion waits for command completion from the device or times out.
+// It returns the payload of the completion message, which carries the command result,
+// or the error of the context once it is done.
+// A completion payload reporting a failed status is returned as a device failure.
+func waitForCompletion(ctx context.Context, route *responseRoute, timeout time.Duration) ([]byte, error) {
+	select {
+	case payload := <-route.complete:
+		if failure := parseDeviceFailure(payload, false); failure != nil {
+			log.Errorf("Command failed on device %s, task %s: %s", route.deviceID, route.taskID, failure.reason)
+			return nil, failure
+		}
+		log.Infof("Command completed for device %s, task %s", route.deviceID, route.taskID)
+		return payload, nil
+	case payload := <-route.fail:
+		failure := parseDeviceFailure(payload, true)
+		log.Errorf("Command failed on device %s, task %s: %s", route.deviceID, route.taskID, failure.reason)
+		return nil, failure
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(timeout):
+		err := fmt.Errorf("%w by device %s, task %s", errCompletionTimeout, route.deviceID, route.taskID)
+		log.Error(err)
+		return nil, err
 	}
 }
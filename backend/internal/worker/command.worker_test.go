@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeviceFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		onFailTopic bool
+		expectFail  bool
+		reason      string
+	}{
+		{
+			name:    "Completion without report",
+			payload: "",
+		},
+		{
+			name:    "Completion reporting success",
+			payload: `{"status":"ok"}`,
+		},
+		{
+			name:    "Completion that is not JSON",
+			payload: "done",
+		},
+		{
+			name:       "Completion reporting failure",
+			payload:    `{"status":"failed","reason":"low battery"}`,
+			expectFail: true,
+			reason:     "low battery",
+		},
+		{
+			name:       "Completion reporting error, case insensitive",
+			payload:    `{"status":"ERROR","error":"valve stuck"}`,
+			expectFail: true,
+			reason:     "valve stuck",
+		},
+		{
+			name:       "Reason preferred over error",
+			payload:    `{"status":"failed","reason":"low battery","error":"E42"}`,
+			expectFail: true,
+			reason:     "low battery",
+		},
+		{
+			name:       "Completion reporting failure without reason",
+			payload:    `{"status":"failed"}`,
+			expectFail: true,
+			reason:     "no reason given",
+		},
+		{
+			name:        "Fail topic with report",
+			payload:     `{"reason":"busy"}`,
+			onFailTopic: true,
+			expectFail:  true,
+			reason:      "busy",
+		},
+		{
+			name:        "Fail topic with plain text",
+			payload:     "out of paper",
+			onFailTopic: true,
+			expectFail:  true,
+			reason:      "out of paper",
+		},
+		{
+			name:        "Fail topic reporting success",
+			payload:     `{"status":"ok"}`,
+			onFailTopic: true,
+			expectFail:  true,
+			reason:      "no reason given",
+		},
+		{
+			name:        "Fail topic without payload",
+			payload:     "",
+			onFailTopic: true,
+			expectFail:  true,
+			reason:      "no reason given",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := parseDeviceFailure([]byte(tt.payload), tt.onFailTopic)
+			if !tt.expectFail {
+				assert.Nil(t, failure)
+				return
+			}
+			if assert.NotNil(t, failure) {
+				assert.Equal(t, tt.reason, failure.reason)
+				assert.Equal(t, []byte(tt.payload), failure.payload)
+			}
+		})
+	}
+}
+
+func TestDeviceTimeouts(t *testing.T) {
+	tests := []struct {
+		name        string
+		dto         models.CommandCreateDTO
+		acknowledge time.Duration
+		completion  time.Duration
+	}{
+		{
+			name:        "Timeouts of the command",
+			dto:         models.CommandCreateDTO{AcknowledgementTimeout: 5, CompletionTimeout: 120},
+			acknowledge: 5 * time.Second,
+			completion:  2 * time.Minute,
+		},
+		{
+			name:        "Default timeouts",
+			dto:         models.CommandCreateDTO{},
+			acknowledge: defaultAcknowledgementTimeout,
+			completion:  defaultCompletionTimeout,
+		},
+		{
+			name:        "Negative timeouts",
+			dto:         models.CommandCreateDTO{AcknowledgementTimeout: -1, CompletionTimeout: -1},
+			acknowledge: defaultAcknowledgementTimeout,
+			completion:  defaultCompletionTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.acknowledge, acknowledgementTimeout(tt.dto))
+			assert.Equal(t, tt.completion, completionTimeout(tt.dto))
+		})
+	}
+}
+
+// newTestRoute returns a route of the given task that is not registered with the response router.
+func newTestRoute(taskID string) *responseRoute {
+	return &responseRoute{
+		deviceID:    "d1",
+		taskID:      taskID,
+		acknowledge: make(chan []byte, responseBuffer),
+		complete:    make(chan []byte, responseBuffer),
+		fail:        make(chan []byte, responseBuffer),
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	route := newTestRoute("t1")
+
+	_, err := waitForAcknowledgement(context.Background(), route, 10*time.Millisecond)
+	assert.ErrorIs(t, err, errAcknowledgementTimeout)
+	assert.Equal(t, db.ExecutionStatusTimedOut, failureStatus(err))
+
+	_, err = waitForCompletion(context.Background(), route, 10*time.Millisecond)
+	assert.ErrorIs(t, err, errCompletionTimeout)
+	assert.Equal(t, db.ExecutionStatusTimedOut, failureStatus(err))
+}
+
+func TestTaskTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		dto      models.CommandCreateDTO
+		expected time.Duration
+	}{
+		{
+			name:     "Acknowledgement and completion",
+			dto:      models.CommandCreateDTO{IsAcknowledgeRequired: true, IsCompletionRequired: true, AcknowledgementTimeout: 5, CompletionTimeout: 30},
+			expected: 35*time.Second + taskTimeoutMargin,
+		},
+		{
+			name:     "Acknowledgement not required",
+			dto:      models.CommandCreateDTO{IsCompletionRequired: true, AcknowledgementTimeout: 5, CompletionTimeout: 30},
+			expected: 30*time.Second + taskTimeoutMargin,
+		},
+		{
+			name:     "Completion not required",
+			dto:      models.CommandCreateDTO{IsAcknowledgeRequired: true, AcknowledgementTimeout: 5, CompletionTimeout: 30},
+			expected: 5*time.Second + taskTimeoutMargin,
+		},
+		{
+			name:     "Fire and forget",
+			dto:      models.CommandCreateDTO{AcknowledgementTimeout: 5, CompletionTimeout: 30},
+			expected: taskTimeoutMargin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, taskTimeout(tt.dto))
+		})
+	}
+}
+
+func TestDevicePayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{name: "JSON object", payload: `{"temperature":21.5}`, expected: `{"temperature":21.5}`},
+		{name: "JSON number", payload: `42`, expected: `42`},
+		{name: "Plain text", payload: `done`, expected: `"done"`},
+		{name: "Empty", payload: ``, expected: ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := devicePayload([]byte(tt.payload))
+			if tt.expected == "" {
+				assert.Nil(t, result)
+				return
+			}
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestWaitReturnsPayload(t *testing.T) {
+	route := newTestRoute("t1")
+	route.acknowledge <- []byte(`{"accepted":true}`)
+	route.complete <- []byte(`{"status":"ok","uptime":3600}`)
+
+	payload, err := waitForAcknowledgement(context.Background(), route, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"accepted":true}`), payload)
+
+	payload, err = waitForCompletion(context.Background(), route, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"status":"ok","uptime":3600}`), payload)
+}
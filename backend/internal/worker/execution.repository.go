@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExecutionRepository struct {
+	db *gorm.DB
+}
+
+func NewExecutionRepository(database *gorm.DB) *ExecutionRepository {
+	return &ExecutionRepository{db: database}
+}
+
+// Create inserts a new execution with its initial status recorded in the history.
+func (r *ExecutionRepository) Create(execution *db.CommandExecution) error {
+	history, err := json.Marshal([]db.ExecutionEvent{{Status: execution.Status, Timestamp: time.Now()}})
+	if err != nil {
+		return err
+	}
+	execution.ExecutionHistory = history
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(execution).Error; err != nil {
+			return err
+		}
+		// Executions rejected for an offline device are created finished
+		if execution.BatchID != nil && db.IsFinalExecutionStatus(execution.Status) {
+			return NewBatchRepository(tx).FinishWithExecution(execution.ID)
+		}
+		return nil
+	})
+}
+
+func (r *ExecutionRepository) FindByID(id string) (*db.CommandExecution, error) {
+	var execution db.CommandExecution
+	if err := r.db.First(&execution, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// FindByIdempotencyKey returns the execution dispatched with the given idempotency key.
+func (r *ExecutionRepository) FindByIdempotencyKey(key string) (*db.CommandExecution, error) {
+	var execution db.CommandExecution
+	if err := r.db.First(&execution, "idempotency_key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// HasIdempotencyKey reports whether an execution issued since the given time holds the idempotency key.
+func (r *ExecutionRepository) HasIdempotencyKey(key string, since time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&db.CommandExecution{}).Where("idempotency_key = ? AND issued_at > ?", key, since).Count(&count).Error
+	return count > 0, err
+}
+
+// ReleaseIdempotencyKey frees the idempotency key of an execution so it can be used by a new dispatch.
+func (r *ExecutionRepository) ReleaseIdempotencyKey(id string) error {
+	return r.db.Model(&db.CommandExecution{}).Where("id = ?", id).Update("idempotency_key", nil).Error
+}
+
+// UpdateStatus moves the execution to the status of the event, appends the event to its history
+// and applies any additional column updates (e.g. timestamps).
+// Cancelled executions are left untouched so a late worker update cannot override the cancellation.
+func (r *ExecutionRepository) UpdateStatus(id string, event db.ExecutionEvent, fields map[string]any) error {
+	_, err := r.applyStatus(id, event, fields, "status <> ?", db.ExecutionStatusCancelled)
+	return err
+}
+
+// Cancel moves an execution that has not reached a final status yet to CANCELLED, in the same statement
+// that checks it, so it cannot override a completion recorded concurrently.
+// It reports false when the execution had already finished and was left untouched.
+func (r *ExecutionRepository) Cancel(id string, event db.ExecutionEvent, fields map[string]any) (bool, error) {
+	return r.applyStatus(id, event, fields, "status NOT IN ?", db.FinalExecutionStatuses)
+}
+
+// TransitionStatus is UpdateStatus for an execution expected in one of the given statuses.
+// It reports false when the execution was in another status and was left untouched.
+func (r *ExecutionRepository) TransitionStatus(id string, from []string, event db.ExecutionEvent, fields map[string]any) (bool, error) {
+	return r.applyStatus(id, event, fields, "status IN ?", from)
+}
+
+// applyStatus moves the execution to the status of the event if it matches the condition, and reports whether it did.
+// An execution reaching a final status finishes its batch, in the same transaction, if it was the last one running.
+func (r *ExecutionRepository) applyStatus(id string, event db.ExecutionEvent, fields map[string]any, condition string, args ...any) (bool, error) {
+	var updated bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&db.CommandExecution{}).
+			Where("id = ?", id).
+			Where(condition, args...).
+			Updates(statusUpdates(event, fields))
+		if result.Error != nil {
+			return result.Error
+		}
+		updated = result.RowsAffected > 0
+		if !updated || !db.IsFinalExecutionStatus(event.Status) {
+			return nil
+		}
+		return NewBatchRepository(tx).FinishWithExecution(id)
+	})
+	return updated, err
+}
+
+// statusUpdates returns the column updates moving an execution to the status of the event.
+func statusUpdates(event db.ExecutionEvent, fields map[string]any) map[string]any {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	// Marshalling a struct of JSON-safe fields cannot fail
+	history, _ := json.Marshal([]db.ExecutionEvent{event})
+
+	updates := map[string]any{
+		"status":            event.Status,
+		"execution_history": gorm.Expr("COALESCE(execution_history, '[]'::jsonb) || ?::jsonb", string(history)),
+	}
+	for column, value := range fields {
+		updates[column] = value
+	}
+	return updates
+}
+
+// FindCommandConfig returns the command configuration an execution was dispatched with.
+func (r *ExecutionRepository) FindCommandConfig(id string) (*db.CommandConfig, error) {
+	var config db.CommandConfig
+	if err := r.db.First(&config, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// FindDeferred returns the executions of the device waiting for it to come online.
+func (r *ExecutionRepository) FindDeferred(deviceID string) ([]db.CommandExecution, error) {
+	var executions []db.CommandExecution
+	err := r.db.Where("device_id = ? AND status = ?", deviceID, db.ExecutionStatusDeferred).
+		Order("issued_at").
+		Find(&executions).Error
+	return executions, err
+}
+
+// FindDeviceStatus returns the presence status of the device, or "" if the device is not registered.
+func (r *ExecutionRepository) FindDeviceStatus(deviceID string) (string, error) {
+	var statuses []string
+	if err := r.db.Model(&db.Device{}).Where("id = ?", deviceID).Pluck("status", &statuses).Error; err != nil {
+		return "", err
+	}
+	if len(statuses) == 0 {
+		return "", nil
+	}
+	return statuses[0], nil
+}
+
+// FindExpired returns up to limit event-driven executions whose device did not respond before their deadline.
+func (r *ExecutionRepository) FindExpired(now time.Time, limit int) ([]db.CommandExecution, error) {
+	var executions []db.CommandExecution
+	err := r.db.Where("event_driven AND status IN ? AND response_deadline < ?", db.AwaitingExecutionStatuses, now).
+		Order("response_deadline").
+		Limit(limit).
+		Find(&executions).Error
+	return executions, err
+}
@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recordingPool is a database connection recording the statements executed on it, each affecting one row.
+// Queries are not supported.
+type recordingPool struct {
+	statements []string
+	args       [][]any
+}
+
+func (p *recordingPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *recordingPool) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	p.statements = append(p.statements, query)
+	p.args = append(p.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (p *recordingPool) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *recordingPool) QueryRowContext(context.Context, string, ...any) *sql.Row {
+	return nil
+}
+
+func (p *recordingPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return &recordingTx{p}, nil
+}
+
+// recordingTx is a transaction of a recordingPool, recording its statements in the pool.
+type recordingTx struct {
+	*recordingPool
+}
+
+func (*recordingTx) Commit() error { return nil }
+
+func (*recordingTx) Rollback() error { return nil }
+
+// newRecordingDB returns a gorm handle executing its statements on a recordingPool.
+func newRecordingDB(t *testing.T) (*gorm.DB, *recordingPool) {
+	pool := &recordingPool{}
+	database, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database, pool
+}
+
+func TestStatusUpdates(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		event  db.ExecutionEvent
+		fields map[string]any
+	}{
+		{
+			name:  "Status only",
+			event: db.ExecutionEvent{Status: db.ExecutionStatusSent, Message: "attempt 1", Timestamp: at},
+		},
+		{
+			name:   "Status with fields",
+			event:  db.ExecutionEvent{Status: db.ExecutionStatusCompleted, Timestamp: at},
+			fields: map[string]any{"completed_at": at, "attempt": 2},
+		},
+		{
+			name:  "Event without timestamp",
+			event: db.ExecutionEvent{Status: db.ExecutionStatusFailed, Message: "device is offline"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := statusUpdates(tt.event, tt.fields)
+
+			assert.Equal(t, tt.event.Status, updates["status"])
+			for column, value := range tt.fields {
+				assert.Equal(t, value, updates[column])
+			}
+			assert.Len(t, updates, len(tt.fields)+2)
+
+			// The event is appended to the history
+			expr, ok := updates["execution_history"].(clause.Expr)
+			if !assert.True(t, ok) || !assert.Len(t, expr.Vars, 1) {
+				return
+			}
+			var history []db.ExecutionEvent
+			assert.NoError(t, json.Unmarshal([]byte(expr.Vars[0].(string)), &history))
+			if assert.Len(t, history, 1) {
+				assert.Equal(t, tt.event.Status, history[0].Status)
+				assert.Equal(t, tt.event.Message, history[0].Message)
+				assert.False(t, history[0].Timestamp.IsZero())
+			}
+		})
+	}
+}
+
+func TestCancelCondition(t *testing.T) {
+	database, pool := newRecordingDB(t)
+	repo := NewExecutionRepository(database)
+
+	event := db.ExecutionEvent{Status: db.ExecutionStatusCancelled, Message: "cancelled by operator"}
+	cancelled, err := repo.Cancel("e1", event, nil)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+
+	// Only executions that have not finished are cancelled, and their batch may finish with them
+	if assert.Len(t, pool.statements, 2) {
+		assert.Contains(t, pool.statements[0], "WHERE id = $")
+		assert.Contains(t, pool.statements[0], "AND status NOT IN ($")
+		for _, status := range db.FinalExecutionStatuses {
+			assert.Contains(t, pool.args[0], status)
+		}
+		assert.Contains(t, pool.statements[1], `UPDATE "command_batches"`)
+	}
+}
+
+func TestUpdateStatusKeepsCancellation(t *testing.T) {
+	database, pool := newRecordingDB(t)
+	repo := NewExecutionRepository(database)
+
+	event := db.ExecutionEvent{Status: db.ExecutionStatusAcknowledged}
+	assert.NoError(t, repo.UpdateStatus("e1", event, nil))
+
+	// A late worker update does not override a cancellation
+	if assert.Len(t, pool.statements, 1) {
+		assert.Contains(t, pool.statements[0], "AND status <> $")
+		assert.Contains(t, pool.args[0], db.ExecutionStatusCancelled)
+	}
+}
+
+func TestReleaseIdempotencyKey(t *testing.T) {
+	database, pool := newRecordingDB(t)
+
+	assert.NoError(t, NewExecutionRepository(database).ReleaseIdempotencyKey("e1"))
+	if assert.Len(t, pool.statements, 1) {
+		assert.Contains(t, pool.statements[0], `SET "idempotency_key"=$1`)
+		assert.Contains(t, pool.statements[0], "WHERE id = $")
+		assert.Nil(t, pool.args[0][0])
+	}
+}
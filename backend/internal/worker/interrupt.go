@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+// asynq does not cancel the context of in-flight tasks on shutdown: it waits for them up to its shutdown timeout,
+// then puts them back in the queue and lets the process exit under them. Tasks waiting for a device stop as soon
+// as the worker starts shutting down instead, so their execution is recorded as REQUEUED and their response
+// routes are released before asynq requeues them.
+var errShuttingDown = errors.New("worker is shutting down")
+
+// stopping is cancelled, with errShuttingDown as cause, when the worker server starts shutting down.
+var stopping, stop = context.WithCancelCause(context.Background())
+
+// interruptible returns a context of the task that is also cancelled when the worker starts shutting down.
+func interruptible(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	unregister := context.AfterFunc(stopping, func() { cancel(context.Cause(stopping)) })
+	return ctx, func() {
+		unregister()
+		cancel(nil)
+	}
+}
+
+// awaitRequeue holds a task interrupted by the shutdown until asynq puts it back in the queue and cancels its context.
+// Unlike returning an error, being requeued on shutdown does not count as a failed attempt.
+func awaitRequeue(ctx context.Context, taskId string) {
+	log.Infof("Task %s interrupted by the shutdown, waiting to be requeued", taskId)
+	<-ctx.Done()
+}
+
+// handleInterruption records that processing stopped before the outcome of the attempt was known.
+// On shutdown the task goes back to the queue as is. A task deadline, or processing cancelled other than
+// by an operator, counts as a failed attempt that is retried while the retry policy allows.
+func handleInterruption(ctx context.Context, p models.CommandCreateDTO, taskId string) error {
+	cause := context.Cause(ctx)
+	fields := map[string]any{"error": cause.Error()}
+	if errors.Is(cause, errShuttingDown) || hasRetriesLeft(ctx, p) {
+		recordEvent(taskId, db.ExecutionEvent{Status: db.ExecutionStatusRequeued, Message: cause.Error()}, fields)
+		return fmt.Errorf("task %s interrupted: %w", taskId, cause)
+	}
+
+	fields["completed_at"] = time.Now()
+	recordEvent(taskId, db.ExecutionEvent{Status: db.ExecutionStatusInterrupted, Message: cause.Error()}, fields)
+	return fmt.Errorf("task %s interrupted: %w: %w", taskId, cause, asynq.SkipRetry)
+}
+
+// isInterrupted reports whether processing of the execution was interrupted with no retries left.
+// asynq retries a task whose deadline expired regardless of the error the worker returns,
+// since it does not wait for the worker to return.
+func isInterrupted(executionID string) bool {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(executionID)
+	return err == nil && execution.Status == db.ExecutionStatusInterrupted
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitStopsWithContext(t *testing.T) {
+	route := newTestRoute("t1")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errShuttingDown)
+
+	_, err := waitForAcknowledgement(ctx, route, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = waitForCompletion(ctx, route, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, context.Cause(ctx), errShuttingDown)
+}
+
+func TestInterruptible(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, stopWaiting := interruptible(parent)
+	defer stopWaiting()
+
+	assert.NoError(t, ctx.Err())
+	cancelParent()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestHandleInterruption(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	shutdown, stop := context.WithCancelCause(context.Background())
+	stop(errShuttingDown)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		status    string
+		skipRetry bool
+	}{
+		{
+			name:      "Deadline expired with no retries left",
+			ctx:       expired,
+			status:    db.ExecutionStatusInterrupted,
+			skipRetry: true,
+		},
+		{
+			name:   "Worker shutting down",
+			ctx:    shutdown,
+			status: db.ExecutionStatusRequeued,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, pool := newRecordingDB(t)
+			handler := db.Handler
+			db.Handler = database
+			t.Cleanup(func() { db.Handler = handler })
+
+			err := handleInterruption(tt.ctx, models.CommandCreateDTO{DeviceID: "d1", MaxRetries: 3}, "e1")
+			assert.ErrorIs(t, err, context.Cause(tt.ctx))
+			assert.Equal(t, tt.skipRetry, errors.Is(err, asynq.SkipRetry))
+			if assert.NotEmpty(t, pool.statements) {
+				assert.Contains(t, pool.args[0], tt.status)
+			}
+		})
+	}
+}
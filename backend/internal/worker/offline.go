@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+// Commands whose config has an offline policy other than "send" are not published to offline devices:
+// they are either rejected, or deferred until the device subscriber sees the device come online.
+// A deferred execution is enqueued to fire at the end of its TTL; releasing it runs the task right away,
+// so a task that fires while the execution is still DEFERRED means the device never came online.
+// Scheduled commands get the policy applied when they fire instead, see applyScheduledOfflinePolicy.
+const defaultDeferTTL = time.Hour
+
+var (
+	// ErrDeviceOffline is the reason recorded on executions rejected because their device is offline.
+	ErrDeviceOffline = errors.New("device is offline")
+	errDeferExpired  = errors.New("device did not come online before the deferral expired")
+	// errDeferred is returned when a scheduled command fires while its device is offline and is deferred.
+	// It is not counted as a failed attempt, see _queue.RegisterNotFailure: the task is retried at the end
+	// of the TTL, unless the device comes online first and releases it.
+	errDeferred = errors.New("device is offline, command deferred")
+)
+
+// isDeviceOffline reports whether the device registry last saw the device go offline.
+// Unknown or unregistered devices are not considered offline.
+func isDeviceOffline(deviceID string) bool {
+	status, err := NewExecutionRepository(db.GetDB()).FindDeviceStatus(deviceID)
+	if err != nil {
+		log.Errorf("Could not look up status of device %s: %v", deviceID, err)
+		return false
+	}
+	return status == db.DeviceStatusOffline
+}
+
+// deferTTL returns how long a deferred command waits for its device to come online.
+func deferTTL(dto models.CommandCreateDTO) time.Duration {
+	if dto.DeferTTL > 0 {
+		return time.Duration(dto.DeferTTL) * time.Second
+	}
+	return defaultDeferTTL
+}
+
+// applyOfflinePolicy sets the initial status of an execution whose device is offline.
+// It reports false when the execution must not be enqueued at all.
+// Scheduled executions are left alone here: the device may be back online by the time they fire.
+func applyOfflinePolicy(execution *db.CommandExecution, dto models.CommandCreateDTO) bool {
+	if execution.ScheduledAt != nil || dto.OfflinePolicy == "" || dto.OfflinePolicy == db.OfflinePolicySend {
+		return true
+	}
+	if !isDeviceOffline(dto.DeviceID) {
+		return true
+	}
+
+	now := time.Now()
+	switch dto.OfflinePolicy {
+	case db.OfflinePolicyReject:
+		execution.Status = db.ExecutionStatusFailed
+		execution.Error = ErrDeviceOffline.Error()
+		execution.CompletedAt = &now
+		return false
+	case db.OfflinePolicyDefer:
+		until := now.Add(deferTTL(dto))
+		execution.Status = db.ExecutionStatusDeferred
+		execution.DeferredUntil = &until
+	}
+	return true
+}
+
+// applyScheduledOfflinePolicy applies the offline policy to a scheduled execution firing for the first time,
+// i.e. still SCHEDULED. It returns an error when the command must not be published now.
+func applyScheduledOfflinePolicy(executionID string, dto models.CommandCreateDTO) error {
+	if dto.OfflinePolicy == "" || dto.OfflinePolicy == db.OfflinePolicySend || !isDeviceOffline(dto.DeviceID) {
+		return nil
+	}
+
+	repo := NewExecutionRepository(db.GetDB())
+	now := time.Now()
+	switch dto.OfflinePolicy {
+	case db.OfflinePolicyReject:
+		event := db.ExecutionEvent{Status: db.ExecutionStatusFailed, Message: ErrDeviceOffline.Error()}
+		fields := map[string]any{"error": ErrDeviceOffline.Error(), "completed_at": now}
+		rejected, err := repo.TransitionStatus(executionID, []string{db.ExecutionStatusScheduled}, event, fields)
+		if err != nil {
+			return fmt.Errorf("reject execution %s: %w", executionID, err)
+		}
+		if rejected {
+			return fmt.Errorf("execution %s: %w: %w", executionID, ErrDeviceOffline, asynq.SkipRetry)
+		}
+	case db.OfflinePolicyDefer:
+		until := now.Add(deferTTL(dto))
+		event := db.ExecutionEvent{Status: db.ExecutionStatusDeferred, Message: "device is offline, deferred until " + until.Format(time.RFC3339)}
+		deferred, err := repo.TransitionStatus(executionID, []string{db.ExecutionStatusScheduled}, event, map[string]any{"deferred_until": until})
+		if err != nil {
+			return fmt.Errorf("defer execution %s: %w", executionID, err)
+		}
+		if deferred {
+			return fmt.Errorf("execution %s: %w", executionID, errDeferred)
+		}
+	}
+	return nil
+}
+
+// ReleaseDeferredExecutions dispatches the executions deferred until the device comes online.
+func ReleaseDeferredExecutions(deviceID string) {
+	repo := NewExecutionRepository(db.GetDB())
+	executions, err := repo.FindDeferred(deviceID)
+	if err != nil {
+		log.Errorf("Could not find deferred executions of device %s: %v", deviceID, err)
+		return
+	}
+
+	inspector := _queue.GetQueueInspector()
+	for _, execution := range executions {
+		event := db.ExecutionEvent{Status: db.ExecutionStatusPending, Message: "device came online"}
+		released, err := repo.TransitionStatus(execution.ID, []string{db.ExecutionStatusDeferred}, event, nil)
+		if err != nil {
+			log.Errorf("Could not release deferred execution %s: %v", execution.ID, err)
+			continue
+		}
+		if !released {
+			// Cancelled, or released by another status message in the meantime
+			continue
+		}
+		if err := inspector.RunTask(execution.Queue, execution.ID); err != nil {
+			if errors.Is(err, asynq.ErrTaskNotFound) {
+				recordFailure(execution.ID, db.ExecutionStatusFailed, fmt.Errorf("release deferred task: %w", err))
+			} else {
+				// Most likely the task fired at the end of its TTL concurrently and is running already
+				log.Warnf("Could not run deferred task %s: %v", execution.ID, err)
+			}
+			continue
+		}
+		log.Infof("Released deferred execution %s of device %s", execution.ID, deviceID)
+	}
+}
+
+// isDeferred reports whether the execution is still waiting for its device to come online.
+func isDeferred(executionID string) bool {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(executionID)
+	return err == nil && execution.Status == db.ExecutionStatusDeferred
+}
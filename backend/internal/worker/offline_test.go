@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferTTL(t *testing.T) {
+	assert.Equal(t, defaultDeferTTL, deferTTL(models.CommandCreateDTO{}))
+	assert.Equal(t, 10*time.Minute, deferTTL(models.CommandCreateDTO{DeferTTL: 600}))
+}
+
+func TestOfflinePolicyNotApplied(t *testing.T) {
+	scheduledAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		execution db.CommandExecution
+		dto       models.CommandCreateDTO
+	}{
+		{
+			name:      "No policy",
+			execution: db.CommandExecution{Status: db.ExecutionStatusPending},
+			dto:       models.CommandCreateDTO{DeviceID: "d1"},
+		},
+		{
+			name:      "Send policy",
+			execution: db.CommandExecution{Status: db.ExecutionStatusPending},
+			dto:       models.CommandCreateDTO{DeviceID: "d1", OfflinePolicy: db.OfflinePolicySend},
+		},
+		{
+			name:      "Scheduled execution",
+			execution: db.CommandExecution{Status: db.ExecutionStatusScheduled, ScheduledAt: &scheduledAt},
+			dto:       models.CommandCreateDTO{DeviceID: "d1", OfflinePolicy: db.OfflinePolicyReject},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execution := tt.execution
+			assert.True(t, applyOfflinePolicy(&execution, tt.dto))
+			assert.Equal(t, tt.execution, execution)
+		})
+	}
+
+	// Commands sent whatever the presence of the device are not checked when their schedule fires either
+	assert.NoError(t, applyScheduledOfflinePolicy("e1", models.CommandCreateDTO{DeviceID: "d1"}))
+	assert.NoError(t, applyScheduledOfflinePolicy("e1", models.CommandCreateDTO{DeviceID: "d1", OfflinePolicy: db.OfflinePolicySend}))
+}
+
+func TestRetryDelayOfDeferredCommand(t *testing.T) {
+	task, err := commandWorker.Generate(models.CommandCreateDTO{DeviceID: "d1", Type: "reboot", DeferTTL: 900})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// A scheduled command deferred when it fired runs again at the end of its TTL, unless released before
+	deferred := fmt.Errorf("execution e1: %w", errDeferred)
+	assert.Equal(t, 15*time.Minute, commandWorker.RetryDelay(0, deferred, task))
+}
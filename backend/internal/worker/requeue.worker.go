@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+// requeueRetries is how many times replacing the task of a retried execution is retried,
+// with asynq's default backoff, before the execution is failed.
+const requeueRetries = 5
+
+// RequeueWorker sends the command of an event-driven execution again once its retry delay elapsed,
+// replacing the completed task of the execution, which a waiting worker leaves to asynq's retries.
+// A device may respond before asynq marks the published task as completed, so the replacement is
+// retried until the task can be deleted rather than waited for on the response path.
+type RequeueWorker struct {
+	jobName string
+}
+
+type requeuePayload struct {
+	ExecutionID string `json:"executionId"`
+}
+
+func NewRequeueWorker(jobName string) *RequeueWorker {
+	return &RequeueWorker{jobName: jobName}
+}
+
+func (rw *RequeueWorker) JobName() string { return rw.jobName }
+
+// Generate builds the task requeueing the given execution.
+func (rw *RequeueWorker) Generate(executionID string) (*asynq.Task, error) {
+	b, err := json.Marshal(requeuePayload{ExecutionID: executionID})
+	if err != nil {
+		return nil, fmt.Errorf("marshal requeue payload: %w", err)
+	}
+	return asynq.NewTask(rw.jobName, b, asynq.Queue(_queue.QueueDefault), asynq.MaxRetry(requeueRetries)), nil
+}
+
+// Process enqueues the command task of the execution again, under the execution ID.
+// Executions cancelled while waiting for their retry are not requeued.
+func (rw *RequeueWorker) Process(ctx context.Context, t *asynq.Task) error {
+	var p requeuePayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("unmarshal requeue payload: %v: %w", err, asynq.SkipRetry)
+	}
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(p.ExecutionID)
+	if err != nil {
+		log.Warnf("Execution %s to requeue not found: %v", p.ExecutionID, err)
+		return nil
+	}
+	if execution.Status != db.ExecutionStatusRetrying {
+		return nil
+	}
+	if err := replaceTask(execution); err != nil {
+		return failRequeue(ctx, execution, err)
+	}
+	return nil
+}
+
+// replaceTask deletes the completed task of the execution and enqueues its command again.
+func replaceTask(execution *db.CommandExecution) error {
+	p, err := executionPayload(execution)
+	if err != nil {
+		return err
+	}
+	t, err := commandWorker.Generate(p)
+	if err != nil {
+		return err
+	}
+	err = _queue.GetQueueInspector().DeleteTask(execution.Queue, execution.ID)
+	if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		return fmt.Errorf("delete task: %w", err)
+	}
+	// A previous attempt may have enqueued the task before failing
+	_, err = EnqueueTask(t, asynq.TaskID(execution.ID), asynq.Queue(execution.Queue))
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+	return nil
+}
+
+// failRequeue fails the execution when requeueing it failed with no retries left, instead of letting asynq
+// archive the requeue and the execution hold its device while RETRYING.
+func failRequeue(ctx context.Context, execution *db.CommandExecution, err error) error {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried < maxRetry {
+		return err
+	}
+	err = fmt.Errorf("requeue task: %w", err)
+	event := db.ExecutionEvent{Status: db.ExecutionStatusFailed, Message: err.Error()}
+	fields := map[string]any{"error": err.Error(), "completed_at": time.Now()}
+	if transitionAwaiting(execution.ID, []string{db.ExecutionStatusRetrying}, event, fields) {
+		releaseDevice(execution.DeviceID, execution.ID)
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_mqtt"
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/replica"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// With several replicas, the response of a device may reach a replica other than the one whose worker
+// is waiting for it: the response subscriptions are shared, so the broker hands each message to one replica only.
+// Every replica records in Redis which tasks it waits on, and relays the responses of the tasks it does not own
+// to the channel of the owning replica.
+const (
+	taskOwnerKeyPrefix   = "dispatcher:task:"
+	replicaChannelPrefix = "dispatcher:replica:"
+	// taskOwnerMargin keeps the owner of a task known slightly longer than the task may wait for responses.
+	taskOwnerMargin = time.Minute
+	// responseClaimTTL only has to outlast the delivery of one message to every replica.
+	responseClaimTTL = 10 * time.Second
+	// relayTimeout bounds the Redis calls relaying one response.
+	relayTimeout = 5 * time.Second
+)
+
+// Responses with no route on this replica are relayed by relayWorkers goroutines rather than in the MQTT callback,
+// which would hold up the messages of every other subscription while waiting on Redis or the database.
+// Responses of the same task go to the same worker so they are applied in the order they were received.
+// A full queue blocks the callback until a worker catches up.
+const (
+	relayWorkers   = 8
+	relayQueueSize = 256
+)
+
+// replicaID identifies this replica in the task owner keys and its relay channel.
+var replicaID = replica.ID
+
+// releaseTaskOwner deletes the owner key of a task only if this replica still owns it,
+// so a replica that finished late does not erase the claim of the replica retrying the task.
+var releaseTaskOwner = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func taskOwnerKey(taskID string) string {
+	return taskOwnerKeyPrefix + taskID + ":owner"
+}
+
+func replicaChannel(id string) string {
+	return replicaChannelPrefix + id + ":responses"
+}
+
+// claimTask records this replica as the one waiting for the responses of the task.
+func claimTask(taskID string, ttl time.Duration) {
+	err := _queue.GetRedisClient().Set(context.Background(), taskOwnerKey(taskID), replicaID, ttl+taskOwnerMargin).Err()
+	if err != nil {
+		log.Errorf("Could not claim responses of task %s: %v", taskID, err)
+	}
+}
+
+// releaseTask forgets that this replica waits for the responses of the task.
+func releaseTask(taskID string) {
+	err := releaseTaskOwner.Run(context.Background(), _queue.GetRedisClient(), []string{taskOwnerKey(taskID)}, replicaID).Err()
+	if err != nil {
+		log.Errorf("Could not release responses of task %s: %v", taskID, err)
+	}
+}
+
+// startRelays starts the workers relaying the responses this replica has no route for.
+func (r *responseRouter) startRelays() {
+	r.relays = make([]chan deviceResponse, relayWorkers)
+	for i := range r.relays {
+		r.relays[i] = make(chan deviceResponse, relayQueueSize)
+		go func(queue <-chan deviceResponse) {
+			for response := range queue {
+				relay(response)
+			}
+		}(r.relays[i])
+	}
+}
+
+// queueRelay hands a response this replica has no route for to its relay worker.
+func (r *responseRouter) queueRelay(response deviceResponse) {
+	h := fnv.New32a()
+	h.Write([]byte(response.TaskID))
+	r.relays[h.Sum32()%uint32(len(r.relays))] <- response
+}
+
+// relay forwards a response this replica has no route for to the replica owning the task,
+// or applies it to the execution when no replica owns the task.
+func relay(response deviceResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	client := _queue.GetRedisClient()
+	owner, err := client.Get(ctx, taskOwnerKey(response.TaskID)).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		// No worker waits for the task: the execution is event-driven, or its worker gave up already
+		if claimResponse(ctx, response) {
+			advanceExecution(response)
+		}
+		return
+	case err == nil && owner == replicaID:
+		log.Warnf("Late or unknown %s from device %s for task %s, dropped", response.Kind, response.DeviceID, response.TaskID)
+		return
+	case err != nil:
+		log.Errorf("Could not look up the owner of task %s, dropped its %s: %v", response.TaskID, response.Kind, err)
+		return
+	case !_mqtt.GetClient().IsShared():
+		// Without shared subscriptions the owner received the response as well
+		log.Debugf("%s from device %s for task %s is handled by replica %s", response.Kind, response.DeviceID, response.TaskID, owner)
+		return
+	}
+
+	// Marshalling a struct of strings and bytes cannot fail
+	message, _ := json.Marshal(response)
+	if err := client.Publish(ctx, replicaChannel(owner), message).Err(); err != nil {
+		log.Errorf("Could not relay %s for task %s to replica %s: %v", response.Kind, response.TaskID, owner, err)
+	}
+}
+
+// claimResponse reports whether this replica applies a response no worker waits for. Without shared subscriptions
+// every replica receives the response, and only the first one to claim it applies it.
+func claimResponse(ctx context.Context, response deviceResponse) bool {
+	if _mqtt.GetClient().IsShared() {
+		return true
+	}
+	// Responses of the same kind differ by their payload, e.g. between the attempts of a task
+	sum := sha256.Sum256(response.Payload)
+	key := taskOwnerKeyPrefix + response.TaskID + ":" + response.Kind + ":" + hex.EncodeToString(sum[:8])
+	claimed, err := _queue.GetRedisClient().SetNX(ctx, key, replicaID, responseClaimTTL).Result()
+	if err != nil {
+		// Applying a response twice is safe, its status transitions are conditional
+		log.Errorf("Could not claim %s for task %s, applying it anyway: %v", response.Kind, response.TaskID, err)
+		return true
+	}
+	return claimed
+}
+
+// listenRelayedResponses delivers the responses other replicas relay to this one.
+func (r *responseRouter) listenRelayedResponses() {
+	pubsub := _queue.GetRedisClient().Subscribe(context.Background(), replicaChannel(replicaID))
+	go func() {
+		defer pubsub.Close()
+		for message := range pubsub.Channel() {
+			var response deviceResponse
+			if err := json.Unmarshal([]byte(message.Payload), &response); err != nil {
+				log.Warnf("Invalid relayed response: %v", err)
+				continue
+			}
+			if !r.deliver(response) {
+				log.Warnf("Late %s from device %s for task %s, dropped", response.Kind, response.DeviceID, response.TaskID)
+			}
+		}
+	}()
+}
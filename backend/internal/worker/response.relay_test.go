@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueRelay(t *testing.T) {
+	router := &responseRouter{relays: make([]chan deviceResponse, relayWorkers)}
+	for i := range router.relays {
+		router.relays[i] = make(chan deviceResponse, relayQueueSize)
+	}
+
+	// Responses of the same task are relayed by the same worker, in the order they were received
+	var sent []deviceResponse
+	for i := range 20 {
+		sent = append(sent, deviceResponse{DeviceID: "d1", Kind: responseAcknowledge, TaskID: fmt.Sprintf("t%d", i%4), Payload: []byte{byte(i)}})
+	}
+	for _, response := range sent {
+		router.queueRelay(response)
+	}
+
+	workers := map[string]int{}
+	received := map[string][]deviceResponse{}
+	for i, queue := range router.relays {
+		for len(queue) > 0 {
+			response := <-queue
+			if worker, ok := workers[response.TaskID]; ok {
+				assert.Equal(t, worker, i, "responses of task %s were split between relay workers", response.TaskID)
+			}
+			workers[response.TaskID] = i
+			received[response.TaskID] = append(received[response.TaskID], response)
+		}
+	}
+	for _, response := range sent {
+		if assert.NotEmpty(t, received[response.TaskID]) {
+			assert.Equal(t, response, received[response.TaskID][0])
+			received[response.TaskID] = received[response.TaskID][1:]
+		}
+	}
+}
+
+func TestRelayKeys(t *testing.T) {
+	assert.Equal(t, "dispatcher:task:t1:owner", taskOwnerKey("t1"))
+	assert.Equal(t, "dispatcher:replica:r1:responses", replicaChannel("r1"))
+}
@@ -0,0 +1,165 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_mqtt"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of responses devices publish on device/{deviceId}/{kind}/{taskId}.
+const (
+	responseAcknowledge = "acknowledge"
+	responseComplete    = "complete"
+	responseFail        = "fail"
+)
+
+// responseTopics are the wildcard subscriptions feeding the response router.
+// They are shared between replicas, see response.relay.go.
+var responseTopics = []string{
+	"device/+/" + responseAcknowledge + "/+",
+	"device/+/" + responseComplete + "/+",
+	"device/+/" + responseFail + "/+",
+}
+
+// responseBuffer is how many responses of each kind are kept for a task until it reads them.
+// Further duplicates are dropped.
+const responseBuffer = 1
+
+// deviceResponse is a response of a device to a task, as routed locally or relayed between replicas.
+type deviceResponse struct {
+	DeviceID string `json:"deviceId"`
+	Kind     string `json:"kind"` // One of the response* constants
+	TaskID   string `json:"taskId"`
+	Payload  []byte `json:"payload"`
+}
+
+// responseRoute receives the responses of the device to one task.
+// Each kind has its own buffered channel, so a completion received while waiting for
+// the acknowledgement is kept for the completion phase.
+type responseRoute struct {
+	deviceID    string
+	taskID      string
+	acknowledge chan []byte
+	complete    chan []byte
+	fail        chan []byte
+}
+
+// responseRouter delivers the device responses received on the shared wildcard subscriptions
+// to the task waiting for them, keyed by task ID.
+type responseRouter struct {
+	mu     sync.Mutex
+	routes map[string]*responseRoute
+	relays []chan deviceResponse
+}
+
+var responses = &responseRouter{routes: map[string]*responseRoute{}}
+
+// subscribe subscribes to the response topics of all devices, shared with the other replicas,
+// and to the responses the other replicas relay to this one.
+func (r *responseRouter) subscribe() error {
+	r.startRelays()
+	for _, topic := range responseTopics {
+		if err := _mqtt.GetClient().SubscribeShared(topic, r.handleMessage, 2); err != nil {
+			return fmt.Errorf("subscribe to %s: %w", topic, err)
+		}
+	}
+	r.listenRelayedResponses()
+	return nil
+}
+
+// register starts routing the responses of the task to the returned route for up to ttl.
+// Registering before publishing the command ensures an immediate response is not missed.
+func (r *responseRouter) register(deviceID, taskID string, ttl time.Duration) *responseRoute {
+	route := &responseRoute{
+		deviceID:    deviceID,
+		taskID:      taskID,
+		acknowledge: make(chan []byte, responseBuffer),
+		complete:    make(chan []byte, responseBuffer),
+		fail:        make(chan []byte, responseBuffer),
+	}
+	claimTask(taskID, ttl)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.routes[taskID]; exists {
+		log.Warnf("Task %s was already waiting for responses, replacing its route", taskID)
+	}
+	r.routes[taskID] = route
+	return route
+}
+
+// unregister stops routing the responses of the task. Later responses are logged and dropped.
+func (r *responseRouter) unregister(route *responseRoute) {
+	r.mu.Lock()
+	current := r.routes[route.taskID] == route
+	if current {
+		delete(r.routes, route.taskID)
+	}
+	r.mu.Unlock()
+
+	if current {
+		releaseTask(route.taskID)
+	}
+}
+
+// handleMessage routes a response to its task, queueing it to be relayed to the owning replica if the task
+// is not waiting here. Local delivery never blocks, so one slow task cannot hold up the others.
+func (r *responseRouter) handleMessage(_ mqtt.Client, msg mqtt.Message) {
+	response, err := parseResponseTopic(msg.Topic())
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	response.Payload = msg.Payload()
+	if !r.deliver(response) {
+		r.queueRelay(response)
+	}
+}
+
+// deliver hands the response to the route of the task, reporting false if the task is not waiting on this replica.
+func (r *responseRouter) deliver(response deviceResponse) bool {
+	r.mu.Lock()
+	route, ok := r.routes[response.TaskID]
+	r.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if route.deviceID != response.DeviceID {
+		log.Warnf("Dropped %s for task %s from device %s, the command was sent to device %s",
+			response.Kind, response.TaskID, response.DeviceID, route.deviceID)
+		return true
+	}
+
+	ch := route.acknowledge
+	switch response.Kind {
+	case responseComplete:
+		ch = route.complete
+	case responseFail:
+		ch = route.fail
+	}
+	select {
+	case ch <- response.Payload:
+	default:
+		log.Debugf("Dropped duplicate %s from device %s for task %s", response.Kind, response.DeviceID, response.TaskID)
+	}
+	return true
+}
+
+// parseResponseTopic splits a device/{deviceId}/{kind}/{taskId} topic.
+func parseResponseTopic(topic string) (deviceResponse, error) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != 4 || levels[0] != "device" || levels[1] == "" || levels[3] == "" {
+		return deviceResponse{}, fmt.Errorf("unexpected response topic %q", topic)
+	}
+	switch levels[2] {
+	case responseAcknowledge, responseComplete, responseFail:
+		return deviceResponse{DeviceID: levels[1], Kind: levels[2], TaskID: levels[3]}, nil
+	default:
+		return deviceResponse{}, fmt.Errorf("unexpected response kind in topic %q", topic)
+	}
+}
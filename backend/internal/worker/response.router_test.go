@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResponseTopic(t *testing.T) {
+	tests := []struct {
+		name      string
+		topic     string
+		expected  deviceResponse
+		expectErr bool
+	}{
+		{
+			name:     "Acknowledgement",
+			topic:    "device/d1/acknowledge/t1",
+			expected: deviceResponse{DeviceID: "d1", Kind: responseAcknowledge, TaskID: "t1"},
+		},
+		{
+			name:     "Completion",
+			topic:    "device/d1/complete/t1",
+			expected: deviceResponse{DeviceID: "d1", Kind: responseComplete, TaskID: "t1"},
+		},
+		{
+			name:     "Failure",
+			topic:    "device/d1/fail/t1",
+			expected: deviceResponse{DeviceID: "d1", Kind: responseFail, TaskID: "t1"},
+		},
+		{
+			name:      "Unknown kind",
+			topic:     "device/d1/status/t1",
+			expectErr: true,
+		},
+		{
+			name:      "Missing task",
+			topic:     "device/d1/acknowledge/",
+			expectErr: true,
+		},
+		{
+			name:      "Missing device",
+			topic:     "device//acknowledge/t1",
+			expectErr: true,
+		},
+		{
+			name:      "Too many levels",
+			topic:     "device/d1/acknowledge/t1/extra",
+			expectErr: true,
+		},
+		{
+			name:      "Other prefix",
+			topic:     "devices/d1/acknowledge/t1",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := parseResponseTopic(tt.topic)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, response)
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	router := &responseRouter{routes: map[string]*responseRoute{}}
+	route := newTestRoute("t1")
+	router.routes["t1"] = route
+
+	// Responses of a task not waiting on this replica are left to the relay
+	assert.False(t, router.deliver(deviceResponse{DeviceID: "d1", Kind: responseAcknowledge, TaskID: "t2"}))
+
+	// Responses of another device are dropped
+	assert.True(t, router.deliver(deviceResponse{DeviceID: "d2", Kind: responseComplete, TaskID: "t1", Payload: []byte("spoofed")}))
+	assert.Empty(t, route.complete)
+
+	// Each kind goes to its own channel, and duplicates are dropped
+	assert.True(t, router.deliver(deviceResponse{DeviceID: "d1", Kind: responseComplete, TaskID: "t1", Payload: []byte("first")}))
+	assert.True(t, router.deliver(deviceResponse{DeviceID: "d1", Kind: responseComplete, TaskID: "t1", Payload: []byte("second")}))
+	assert.True(t, router.deliver(deviceResponse{DeviceID: "d1", Kind: responseFail, TaskID: "t1", Payload: []byte("failed")}))
+	assert.Empty(t, route.acknowledge)
+	assert.Equal(t, []byte("first"), <-route.complete)
+	assert.Empty(t, route.complete)
+	assert.Equal(t, []byte("failed"), <-route.fail)
+}
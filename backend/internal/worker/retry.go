@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	defaultRetryDelay = 10 * time.Second
+	// maxRetryDelay caps the exponential backoff.
+	maxRetryDelay = time.Hour
+)
+
+// RetryDelay computes the delay before the n-th retry of a command task from the retry policy it carries.
+func (*CommandWorker) RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if errors.Is(err, errDeviceBusy) {
+		return busyRetryDelay
+	}
+	var p models.CommandCreateDTO
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return defaultRetryDelay
+	}
+	if errors.Is(err, errDeferred) {
+		return deferTTL(p)
+	}
+	return backoff(p, n)
+}
+
+// backoff returns the delay before the retry following n previous retries.
+func backoff(p models.CommandCreateDTO, n int) time.Duration {
+	delay := defaultRetryDelay
+	if p.RetryDelay > 0 {
+		delay = time.Duration(p.RetryDelay) * time.Second
+	}
+	if p.RetryBackoff != db.RetryBackoffExponential {
+		return delay
+	}
+
+	for i := 0; i < n && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+	// Equal jitter: keep half of the delay and randomize the other half so devices don't retry in lockstep
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryClass returns the CommandConfig.RetryOn entry an error falls under, or "" if it is not a device failure.
+func retryClass(err error) string {
+	var deviceErr *deviceFailureError
+	switch {
+	case errors.Is(err, errAcknowledgementTimeout):
+		return db.RetryOnAcknowledgementTimeout
+	case errors.Is(err, errCompletionTimeout):
+		return db.RetryOnCompletionTimeout
+	case errors.As(err, &deviceErr):
+		return db.RetryOnDeviceFailure
+	}
+	return ""
+}
+
+// isRetryable reports whether the retry policy of the command allows retrying after the error.
+func isRetryable(p models.CommandCreateDTO, err error) bool {
+	class := retryClass(err)
+	return class == "" || slices.Contains(p.RetryOn, class)
+}
+
+// hasRetriesLeft reports whether the retry policy of the command allows another attempt if this one fails.
+// The command's own MaxRetries is used since the task may carry a spare retry, see CommandWorker.Generate.
+func hasRetriesLeft(ctx context.Context, p models.CommandCreateDTO) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	return ok && canRetry(p, retried)
+}
+
+// canRetry reports whether the retry policy of the command allows another attempt after the given number of retries.
+func canRetry(p models.CommandCreateDTO, retried int) bool {
+	return retried < p.MaxRetries
+}
+
+// attempt returns the number of the current attempt at processing the task, starting at 1.
+func attempt(ctx context.Context) int {
+	retried, _ := asynq.GetRetryCount(ctx)
+	return retried + 1
+}
@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		dto      models.CommandCreateDTO
+		retries  int
+		min, max time.Duration
+	}{
+		{
+			name:    "Default delay",
+			dto:     models.CommandCreateDTO{},
+			retries: 3,
+			min:     defaultRetryDelay,
+			max:     defaultRetryDelay,
+		},
+		{
+			name:    "Fixed delay",
+			dto:     models.CommandCreateDTO{RetryDelay: 30, RetryBackoff: db.RetryBackoffFixed},
+			retries: 5,
+			min:     30 * time.Second,
+			max:     30 * time.Second,
+		},
+		{
+			name:    "Exponential first retry",
+			dto:     models.CommandCreateDTO{RetryDelay: 10, RetryBackoff: db.RetryBackoffExponential},
+			retries: 0,
+			min:     5 * time.Second,
+			max:     10 * time.Second,
+		},
+		{
+			name:    "Exponential doubles on every retry",
+			dto:     models.CommandCreateDTO{RetryDelay: 10, RetryBackoff: db.RetryBackoffExponential},
+			retries: 3,
+			min:     40 * time.Second,
+			max:     80 * time.Second,
+		},
+		{
+			name:    "Exponential capped",
+			dto:     models.CommandCreateDTO{RetryDelay: 10, RetryBackoff: db.RetryBackoffExponential},
+			retries: 20,
+			min:     maxRetryDelay / 2,
+			max:     maxRetryDelay,
+		},
+		{
+			name:    "Exponential capped without overflow",
+			dto:     models.CommandCreateDTO{RetryDelay: 10, RetryBackoff: db.RetryBackoffExponential},
+			retries: 1000,
+			min:     maxRetryDelay / 2,
+			max:     maxRetryDelay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The jitter is random, so the bounds are checked over many draws
+			for range 100 {
+				delay := backoff(tt.dto, tt.retries)
+				assert.GreaterOrEqual(t, delay, tt.min)
+				assert.LessOrEqual(t, delay, tt.max)
+			}
+		})
+	}
+}
+
+func TestRetryClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "Acknowledgement timeout",
+			err:      fmt.Errorf("%w by device d1, task t1", errAcknowledgementTimeout),
+			expected: db.RetryOnAcknowledgementTimeout,
+		},
+		{
+			name:     "Completion timeout",
+			err:      fmt.Errorf("%w by device d1, task t1", errCompletionTimeout),
+			expected: db.RetryOnCompletionTimeout,
+		},
+		{
+			name:     "Device failure",
+			err:      fmt.Errorf("attempt 1: %w", &deviceFailureError{reason: "low battery"}),
+			expected: db.RetryOnDeviceFailure,
+		},
+		{
+			name:     "Other error",
+			err:      errors.New("publish failed"),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, retryClass(tt.err))
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		retryOn  []string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Other errors are always retryable",
+			retryOn:  nil,
+			err:      errors.New("publish failed"),
+			expected: true,
+		},
+		{
+			name:     "Device failure not listed",
+			retryOn:  []string{db.RetryOnAcknowledgementTimeout},
+			err:      &deviceFailureError{reason: "low battery"},
+			expected: false,
+		},
+		{
+			name:     "Device failure listed",
+			retryOn:  []string{db.RetryOnDeviceFailure},
+			err:      &deviceFailureError{reason: "low battery"},
+			expected: true,
+		},
+		{
+			name:     "Timeout not listed",
+			retryOn:  []string{db.RetryOnAcknowledgementTimeout},
+			err:      errCompletionTimeout,
+			expected: false,
+		},
+		{
+			name:     "Timeout listed",
+			retryOn:  []string{db.RetryOnAcknowledgementTimeout, db.RetryOnCompletionTimeout},
+			err:      errCompletionTimeout,
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRetryable(models.CommandCreateDTO{RetryOn: tt.retryOn}, tt.err))
+		})
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		retried    int
+		expected   bool
+	}{
+		{name: "No retries", maxRetries: 0, retried: 0, expected: false},
+		{name: "First retry", maxRetries: 2, retried: 0, expected: true},
+		{name: "Last retry", maxRetries: 2, retried: 1, expected: true},
+		{name: "Retries used up", maxRetries: 2, retried: 2, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, canRetry(models.CommandCreateDTO{MaxRetries: tt.maxRetries}, tt.retried))
+		})
+	}
+}
+
+func TestHasRetriesLeft(t *testing.T) {
+	// Outside of a task there is no retry count, so no retry can be relied on
+	assert.False(t, hasRetriesLeft(context.Background(), models.CommandCreateDTO{MaxRetries: 3}))
+}
+
+func TestRetryDelayOfBusyDevice(t *testing.T) {
+	task, err := commandWorker.Generate(models.CommandCreateDTO{DeviceID: "d1", Type: "reboot", RetryDelay: 5})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	busy := fmt.Errorf("%w: device d1, task t1", errDeviceBusy)
+	assert.Equal(t, busyRetryDelay, commandWorker.RetryDelay(3, busy, task))
+	assert.Equal(t, 5*time.Second, commandWorker.RetryDelay(3, errAcknowledgementTimeout, task))
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// rolloutCheckInterval is how often the progress of the current wave of a rollout is checked.
+	rolloutCheckInterval = 10 * time.Second
+	// rolloutCheckRetries is how many times a failed check is retried, with asynq's default backoff,
+	// before the rollout is paused.
+	rolloutCheckRetries = 10
+)
+
+// RolloutWorker checks the current wave of a rolling out batch, and dispatches the next wave
+// or pauses the rollout depending on the outcome of its executions.
+type RolloutWorker struct {
+	jobName string
+}
+
+type rolloutPayload struct {
+	BatchID string `json:"batchId"`
+	Wave    int    `json:"wave"`
+}
+
+func NewRolloutWorker(jobName string) *RolloutWorker {
+	return &RolloutWorker{jobName: jobName}
+}
+
+func (rw *RolloutWorker) JobName() string { return rw.jobName }
+
+// Generate builds the task checking the given wave of the batch.
+func (rw *RolloutWorker) Generate(batchID string, wave int) (*asynq.Task, error) {
+	b, err := json.Marshal(rolloutPayload{BatchID: batchID, Wave: wave})
+	if err != nil {
+		return nil, fmt.Errorf("marshal rollout payload: %w", err)
+	}
+	return asynq.NewTask(rw.jobName, b, asynq.Queue(_queue.QueueDefault), asynq.MaxRetry(rolloutCheckRetries)), nil
+}
+
+// Process evaluates the wave once all its executions finished, or earlier if its failure rate is exceeded.
+// The check is re-enqueued until the wave is decided; checks of a batch that moved on are dropped.
+func (rw *RolloutWorker) Process(ctx context.Context, t *asynq.Task) error {
+	var p rolloutPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("unmarshal rollout payload: %v: %w", err, asynq.SkipRetry)
+	}
+	if err := checkWave(p); err != nil {
+		return failRolloutCheck(ctx, p, err)
+	}
+	return nil
+}
+
+// checkWave dispatches the next wave of the rollout, pauses it or schedules the next check of the wave.
+func checkWave(p rolloutPayload) error {
+	repo := NewBatchRepository(db.GetDB())
+	batch, err := repo.FindByID(p.BatchID)
+	if err != nil {
+		log.Warnf("Batch %s of rollout check not found: %v", p.BatchID, err)
+		return nil
+	}
+	if batch.Status != db.BatchStatusRunning || batch.Wave != p.Wave {
+		return nil
+	}
+
+	devices := batch.WaveDeviceIDs()
+	progress, err := repo.Progress(batch.ID, devices...)
+	if err != nil {
+		return err
+	}
+	size := int64(len(devices))
+	failed := progress[db.ExecutionStatusFailed] + progress[db.ExecutionStatusTimedOut] + progress[db.ExecutionStatusInterrupted]
+	var finished int64
+	for _, status := range db.FinalExecutionStatuses {
+		finished += progress[status]
+	}
+
+	policy := batch.Rollout
+	if policy.MaxFailureRate > 0 && failed*100 > int64(policy.MaxFailureRate)*size {
+		return pauseRollout(repo, batch, fmt.Sprintf("%d of %d executions of wave %d failed, more than %d%%",
+			failed, size, batch.Wave, policy.MaxFailureRate))
+	}
+	if finished < size {
+		return scheduleRolloutCheck(batch.ID, batch.Wave, time.Now().Add(rolloutCheckInterval))
+	}
+	completed := progress[db.ExecutionStatusCompleted]
+	if completed*100 < int64(policy.SuccessThreshold)*size {
+		return pauseRollout(repo, batch, fmt.Sprintf("%d of %d executions of wave %d completed, less than %d%%",
+			completed, size, batch.Wave, policy.SuccessThreshold))
+	}
+
+	return dispatchNextWave(batch, nil)
+}
+
+// failRolloutCheck pauses the rollout when its check failed with no retries left, instead of letting asynq
+// archive the check and the rollout stop without notice. The rollout can then be resumed or aborted.
+func failRolloutCheck(ctx context.Context, p rolloutPayload, err error) error {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried < maxRetry {
+		return err
+	}
+	reason := fmt.Sprintf("checking wave %d failed: %v", p.Wave, err)
+	log.Warnf("Pausing rollout of batch %s: %s", p.BatchID, reason)
+	if pauseErr := NewBatchRepository(db.GetDB()).Pause(p.BatchID, p.Wave, reason); pauseErr != nil {
+		log.Errorf("Could not pause rollout of batch %s: %v", p.BatchID, pauseErr)
+	}
+	return err
+}
+
+func pauseRollout(repo *BatchRepository, batch *db.CommandBatch, reason string) error {
+	log.Warnf("Pausing rollout of batch %s: %s", batch.ID, reason)
+	return repo.Pause(batch.ID, batch.Wave, reason)
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(database *gorm.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: database}
+}
+
+// FindEnabled returns the schedules the periodic task manager must register.
+func (r *ScheduleRepository) FindEnabled() ([]db.CommandSchedule, error) {
+	var schedules []db.CommandSchedule
+	if err := r.db.Find(&schedules, "enabled = ?", true).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// FindByID returns the schedule along with the command configuration it dispatches.
+func (r *ScheduleRepository) FindByID(id string) (*db.CommandSchedule, error) {
+	var schedule db.CommandSchedule
+	if err := r.db.Preload("CommandConfig").First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// RecordRun stores the outcome of a run and when the schedule fires next.
+// A run dispatches either a single execution or a batch; the other ID is empty.
+func (r *ScheduleRepository) RecordRun(id string, runAt time.Time, nextRunAt *time.Time, executionID, batchID string) error {
+	return r.db.Model(&db.CommandSchedule{}).Where("id = ?", id).Updates(map[string]any{
+		"last_run_at":       runAt,
+		"next_run_at":       nextRunAt,
+		"last_execution_id": executionID,
+		"last_batch_id":     batchID,
+	}).Error
+}
@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// scheduleSyncInterval is how often schedules are reloaded from the database by the periodic task manager.
+	scheduleSyncInterval = 30 * time.Second
+	// scheduleUniqueTTL prevents the same run from being enqueued twice when several schedulers are running.
+	scheduleUniqueTTL = 30 * time.Second
+)
+
+// MinScheduleInterval is the shortest interval between two runs of a schedule. Runs closer together than
+// the uniqueness window of their task would be dropped as duplicates.
+const MinScheduleInterval = scheduleUniqueTTL
+
+// ScheduleWorker dispatches the command of a CommandSchedule every time its cron expression fires.
+type ScheduleWorker struct {
+	jobName string
+}
+
+type schedulePayload struct {
+	ScheduleID string `json:"scheduleId"`
+}
+
+func NewScheduleWorker(jobName string) *ScheduleWorker {
+	return &ScheduleWorker{jobName: jobName}
+}
+
+func (sw *ScheduleWorker) JobName() string { return sw.jobName }
+
+// Generate builds the task enqueued on every run of the schedule.
+func (sw *ScheduleWorker) Generate(schedule *db.CommandSchedule) (*asynq.Task, error) {
+	b, err := json.Marshal(schedulePayload{ScheduleID: schedule.ID})
+	if err != nil {
+		return nil, fmt.Errorf("marshal schedule payload: %w", err)
+	}
+	return asynq.NewTask(sw.jobName, b, asynq.Queue(_queue.QueueDefault), asynq.MaxRetry(3), asynq.Unique(scheduleUniqueTTL)), nil
+}
+
+// Process dispatches the scheduled command and records the run on the schedule.
+func (sw *ScheduleWorker) Process(ctx context.Context, t *asynq.Task) error {
+	var p schedulePayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("unmarshal schedule payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	repo := NewScheduleRepository(db.GetDB())
+	schedule, err := repo.FindByID(p.ScheduleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warnf("Schedule %s no longer exists, skipping run", p.ScheduleID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !schedule.Enabled {
+		log.Infof("Schedule %s is disabled, skipping run", schedule.ID)
+		return nil
+	}
+	if schedule.CommandConfig.ID == "" {
+		log.Warnf("Command config %s of schedule %s no longer exists, skipping run", schedule.CommandConfigID, schedule.ID)
+		return nil
+	}
+
+	dto := models.CommandExecuteDTO{
+		Description: "Scheduled by " + schedule.Name,
+		DeviceID:    schedule.DeviceID,
+		Parameters:  schedule.Parameters,
+	}
+	command := dto.ToCommand(&schedule.CommandConfig)
+
+	var executionID, batchID string
+	if schedule.DeviceID != "" {
+		execution, err := EnqueueCommandExecutionTask(command, DispatchOptions{})
+		if err != nil {
+			return fmt.Errorf("dispatch schedule %s: %w", schedule.ID, err)
+		}
+		executionID = execution.ID
+		log.Infof("Schedule %s dispatched execution %s to device %s", schedule.ID, execution.ID, schedule.DeviceID)
+	} else {
+		// Groups and selectors are resolved at every run so the schedule follows membership changes
+		opts := DispatchOptions{Selector: schedule.Selector}
+		if schedule.GroupID != nil {
+			opts.GroupID = *schedule.GroupID
+		}
+		deviceIDs, err := ResolveTargetDevices(opts.GroupID, opts.Selector)
+		switch {
+		case errors.Is(err, ErrNoTargetDevices), errors.Is(err, ErrDeviceGroupNotFound), errors.Is(err, ErrInvalidSelector):
+			log.Warnf("Schedule %s has no device to dispatch to: %v", schedule.ID, err)
+		case err != nil:
+			return fmt.Errorf("resolve targets of schedule %s: %w", schedule.ID, err)
+		default:
+			batch, err := EnqueueCommandBatch(command, deviceIDs, opts)
+			if err != nil {
+				return fmt.Errorf("dispatch schedule %s: %w", schedule.ID, err)
+			}
+			batchID = batch.ID
+			log.Infof("Schedule %s dispatched batch %s to %d devices", schedule.ID, batch.ID, len(deviceIDs))
+		}
+	}
+
+	now := time.Now()
+	var nextRunAt *time.Time
+	if next, err := schedule.NextRun(now); err == nil {
+		nextRunAt = &next
+	}
+	if err := repo.RecordRun(schedule.ID, now, nextRunAt, executionID, batchID); err != nil {
+		log.Errorf("Could not record run of schedule %s: %v", schedule.ID, err)
+	}
+	return nil
+}
+
+// scheduleConfigProvider feeds the enabled schedules, and the sweep of event-driven executions,
+// to the asynq periodic task manager.
+type scheduleConfigProvider struct {
+	worker  *ScheduleWorker
+	sweeper *SweepWorker
+}
+
+// GetConfigs implements asynq.PeriodicTaskConfigProvider.
+func (p *scheduleConfigProvider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	schedules, err := NewScheduleRepository(db.GetDB()).FindEnabled()
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]*asynq.PeriodicTaskConfig, 0, len(schedules)+1)
+	// Executions dispatched in event-driven mode are swept whatever the current mode
+	configs = append(configs, &asynq.PeriodicTaskConfig{Cronspec: "@every " + sweepInterval.String(), Task: p.sweeper.Generate()})
+	for i := range schedules {
+		task, err := p.worker.Generate(&schedules[i])
+		if err != nil {
+			log.Errorf("Could not build task of schedule %s: %v", schedules[i].ID, err)
+			continue
+		}
+		configs = append(configs, &asynq.PeriodicTaskConfig{Cronspec: schedules[i].Cronspec(), Task: task})
+	}
+	return configs, nil
+}
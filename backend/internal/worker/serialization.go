@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// Commands of a device run one at a time, in the order they were dispatched.
+// Each device has a Redis list of execution IDs: the head is the execution allowed to run.
+// Executions join the list when they are enqueued, or when they are first picked up by a worker if they were
+// scheduled or deferred. A task picked up before its execution reaches the head is parked in the retry set,
+// and the execution releasing the device hands it over by running the task of the next one.
+const (
+	deviceQueueKeyPrefix = "dispatcher:device:"
+	// deviceQueueTTL expires the list of a device that no longer receives commands.
+	deviceQueueTTL = 24 * time.Hour
+	// busyRetryDelay runs a parked task again in case the hand-off missed it, e.g. because the device
+	// was released while the task was being parked.
+	busyRetryDelay = 30 * time.Second
+	// maxStaleHeads bounds how many finished executions are dropped from the head of the list at once.
+	maxStaleHeads = 10
+)
+
+// errDeviceBusy is returned when another command of the device is in flight.
+// It is not counted as a failed attempt, see _queue.RegisterNotFailure.
+var errDeviceBusy = errors.New("device is busy with another command")
+
+// joinDeviceQueue appends the execution to the list of the device unless it is already in it,
+// and returns the head of the list.
+var joinDeviceQueue = redis.NewScript(`
+if not redis.call('LPOS', KEYS[1], ARGV[1]) then
+	redis.call('RPUSH', KEYS[1], ARGV[1])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return redis.call('LINDEX', KEYS[1], 0)
+`)
+
+// leaveDeviceQueue removes the execution from the list of the device and, if the execution was the head,
+// returns the new head of the list.
+var leaveDeviceQueue = redis.NewScript(`
+local head = redis.call('LINDEX', KEYS[1], 0)
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+if head ~= ARGV[1] then
+	return false
+end
+return redis.call('LINDEX', KEYS[1], 0)
+`)
+
+func deviceQueueKey(deviceID string) string {
+	return deviceQueueKeyPrefix + deviceID + ":queue"
+}
+
+// acquireDevice reports whether the execution may run now, i.e. no earlier command of the device is still in flight.
+// Executions left at the head by a crashed worker are detected and dropped.
+func acquireDevice(ctx context.Context, deviceID, executionID string) (bool, string, error) {
+	client := _queue.GetRedisClient()
+	key := deviceQueueKey(deviceID)
+
+	for range maxStaleHeads {
+		head, err := joinDeviceQueue.Run(ctx, client, []string{key}, executionID, deviceQueueTTL.Milliseconds()).Text()
+		if err != nil {
+			return false, "", fmt.Errorf("join queue of device %s: %w", deviceID, err)
+		}
+		if head == executionID {
+			return true, head, nil
+		}
+		if !isStale(head) {
+			return false, head, nil
+		}
+		log.Warnf("Dropping stale execution %s from the queue of device %s", head, deviceID)
+		next, err := leaveDeviceQueue.Run(ctx, client, []string{key}, head).Text()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return false, "", fmt.Errorf("drop execution %s from queue of device %s: %w", head, deviceID, err)
+		}
+		if next != "" && next != executionID {
+			wakeExecution(next)
+		}
+	}
+	return false, "", nil
+}
+
+// queueOnDevice appends the execution to the list of its device when it is enqueued, so it runs after
+// the commands dispatched before it, whenever its task is picked up.
+func queueOnDevice(deviceID, executionID string) error {
+	key := deviceQueueKey(deviceID)
+	err := joinDeviceQueue.Run(context.Background(), _queue.GetRedisClient(), []string{key}, executionID, deviceQueueTTL.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("join queue of device %s: %w", deviceID, err)
+	}
+	return nil
+}
+
+// releaseDevice removes the execution from the list of its device, and hands the device over to the next command.
+func releaseDevice(deviceID, executionID string) {
+	next, err := leaveDeviceQueue.Run(context.Background(), _queue.GetRedisClient(), []string{deviceQueueKey(deviceID)}, executionID).Text()
+	if errors.Is(err, redis.Nil) {
+		return
+	}
+	if err != nil {
+		log.Errorf("Could not release device %s from execution %s: %v", deviceID, executionID, err)
+		return
+	}
+	wakeExecution(next)
+}
+
+// wakeExecution runs the parked task of the execution now rather than at its next busy retry.
+// A task that is not parked, e.g. still pending, finds the device free when it is picked up.
+func wakeExecution(executionID string) {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(executionID)
+	if err != nil {
+		log.Warnf("Could not find execution %s to hand its device over: %v", executionID, err)
+		return
+	}
+	if err := _queue.GetQueueInspector().RunTask(execution.Queue, executionID); err != nil {
+		log.Debugf("Execution %s was not parked: %v", executionID, err)
+	}
+}
+
+// isStale reports whether an execution holding a device will never run again.
+func isStale(executionID string) bool {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(executionID)
+	if err != nil {
+		return true
+	}
+	if execution.IsFinished() {
+		return true
+	}
+	// The task of an event-driven execution completes once published, the sweeper finishes the execution
+	if execution.AwaitsResponse() {
+		return false
+	}
+	info, err := _queue.GetQueueInspector().GetTaskInfo(execution.Queue, executionID)
+	if errors.Is(err, asynq.ErrTaskNotFound) {
+		return true
+	}
+	return err == nil && (info.State == asynq.TaskStateArchived || info.State == asynq.TaskStateCompleted)
+}
+
+// recordQueuedBehind marks the execution as waiting for another one, once.
+func recordQueuedBehind(executionID, headID string) {
+	execution, err := NewExecutionRepository(db.GetDB()).FindByID(executionID)
+	if err != nil || execution.Status == db.ExecutionStatusQueuedBehind {
+		return
+	}
+	recordStatus(executionID, db.ExecutionStatusQueuedBehind, "waiting for execution "+headID, nil)
+}
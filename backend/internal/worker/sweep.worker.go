@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// sweepInterval is how often event-driven executions are checked against their response deadline.
+	sweepInterval = 10 * time.Second
+	// sweepBatchSize bounds how many expired executions one sweep times out.
+	sweepBatchSize = 500
+)
+
+// SweepWorker times out the event-driven executions whose device did not respond before their deadline,
+// which a waiting worker does itself in blocking mode.
+type SweepWorker struct {
+	jobName string
+}
+
+func NewSweepWorker(jobName string) *SweepWorker {
+	return &SweepWorker{jobName: jobName}
+}
+
+func (sw *SweepWorker) JobName() string { return sw.jobName }
+
+// Generate builds the sweep task. Every replica schedules it, so it is unique for one interval.
+func (sw *SweepWorker) Generate() *asynq.Task {
+	return asynq.NewTask(sw.jobName, nil, asynq.Queue(_queue.QueueDefault), asynq.MaxRetry(0), asynq.Unique(sweepInterval))
+}
+
+// Process times out the expired executions, retrying them if their retry policy allows.
+// Executions left over by a full batch are handled by the next sweep.
+func (sw *SweepWorker) Process(ctx context.Context, _ *asynq.Task) error {
+	executions, err := NewExecutionRepository(db.GetDB()).FindExpired(time.Now(), sweepBatchSize)
+	if err != nil {
+		return fmt.Errorf("find expired executions: %w", err)
+	}
+	for i := range executions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		expireExecution(&executions[i])
+	}
+	if len(executions) > 0 {
+		log.Infof("Swept %d expired executions", len(executions))
+	}
+	return nil
+}
+
+// expireExecution times out an execution whose device did not send the response it waits for.
+// A response received since the execution was found moves it on, and is not overridden.
+func expireExecution(execution *db.CommandExecution) {
+	from := []string{execution.Status}
+	p, err := executionPayload(execution)
+	if err != nil {
+		// Without its command the retry policy is unknown, so the execution cannot be retried
+		event := db.ExecutionEvent{Status: db.ExecutionStatusTimedOut, Message: err.Error()}
+		fields := map[string]any{"error": err.Error(), "completed_at": time.Now(), "response_deadline": nil}
+		if transitionAwaiting(execution.ID, from, event, fields) {
+			releaseDevice(execution.DeviceID, execution.ID)
+		}
+		return
+	}
+
+	timeout := errCompletionTimeout
+	if execution.Status == db.ExecutionStatusSent && p.IsAcknowledgeRequired {
+		timeout = errAcknowledgementTimeout
+	}
+	failExecution(execution, from, p, fmt.Errorf("%w by device %s, task %s", timeout, execution.DeviceID, execution.ID))
+}
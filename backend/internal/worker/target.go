@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"command-dispatcher/internal/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeviceGroupNotFound = errors.New("device group not found")
+	// ErrInvalidSelector wraps the reason a tag selector could not be parsed.
+	ErrInvalidSelector = errors.New("invalid tag selector")
+	// ErrNoTargetDevices is returned when a group or a tag selector resolves to no device.
+	ErrNoTargetDevices = errors.New("no device matches the target")
+)
+
+// ResolveTargetDevices returns the devices a command targeting a device group or a tag selector is dispatched to.
+// Targets are resolved when the command is dispatched; the resulting list is frozen on the batch.
+func ResolveTargetDevices(groupID, selector string) ([]string, error) {
+	repo := NewTargetRepository(db.GetDB())
+
+	var deviceIDs []string
+	switch {
+	case groupID != "":
+		ids, err := repo.FindGroupDeviceIDs(groupID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceGroupNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		deviceIDs = ids
+	case selector != "":
+		tags, err := models.ParseTagSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSelector, err)
+		}
+		if deviceIDs, err = repo.FindDeviceIDsByTags(tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(deviceIDs) == 0 {
+		return nil, ErrNoTargetDevices
+	}
+	return deviceIDs, nil
+}
+
+// FindUnknownDevices returns the positions in deviceIDs of the devices that are not in the device registry.
+func FindUnknownDevices(deviceIDs []string) ([]int, error) {
+	return NewTargetRepository(db.GetDB()).FindUnknownDevices(deviceIDs)
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/db"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+type TargetRepository struct {
+	db *gorm.DB
+}
+
+func NewTargetRepository(database *gorm.DB) *TargetRepository {
+	return &TargetRepository{db: database}
+}
+
+// FindGroupDeviceIDs returns the IDs of the members of the group, ordered by ID.
+func (r *TargetRepository) FindGroupDeviceIDs(groupID string) ([]string, error) {
+	if err := r.db.Select("id").First(&db.DeviceGroup{}, "id = ?", groupID).Error; err != nil {
+		return nil, err
+	}
+	var ids []string
+	err := r.db.Table("device_group_members").
+		Where("device_group_id = ?", groupID).
+		Order("device_id").
+		Pluck("device_id", &ids).Error
+	return ids, err
+}
+
+// FindDeviceIDsByTags returns the IDs of the devices having all the given tags, ordered by ID.
+func (r *TargetRepository) FindDeviceIDsByTags(tags map[string]string) ([]string, error) {
+	// Marshalling a map of strings cannot fail
+	containment, _ := json.Marshal(tags)
+	var ids []string
+	err := r.db.Model(&db.Device{}).
+		Where("tags @> ?::jsonb", string(containment)).
+		Order("id").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// FindUnknownDevices returns the positions in ids of the devices that are not in the device registry.
+func (r *TargetRepository) FindUnknownDevices(ids []string) ([]int, error) {
+	var known []string
+	if err := r.db.Model(&db.Device{}).Where("id IN ?", ids).Pluck("id", &known).Error; err != nil {
+		return nil, err
+	}
+	registered := make(map[string]struct{}, len(known))
+	for _, id := range known {
+		registered[id] = struct{}{}
+	}
+	var unknown []int
+	for i, id := range ids {
+		if _, ok := registered[id]; !ok {
+			unknown = append(unknown, i)
+		}
+	}
+	return unknown, nil
+}
@@ -1,56 +1,337 @@
 package worker
 
 import (
+	"command-dispatcher/internal/config/_mqtt"
 	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
 	"command-dispatcher/internal/models"
 	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/hibiken/asynq"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // TypeCommandExecutionJob Task type identifiers for the command domain.
 const (
 	TypeCommandExecutionJob = "command:execute"
+	TypeCommandScheduleJob  = "command:schedule"
+	TypeBatchRolloutJob     = "batch:rollout"
+	TypeResponseSweepJob    = "command:sweep"
+	TypeCommandRequeueJob   = "command:requeue"
 )
 
+// priorityQueue returns the asynq queue commands of the given priority are enqueued on.
+func priorityQueue(priority string) string {
+	switch priority {
+	case db.PriorityHigh:
+		return _queue.QueueCritical
+	case db.PriorityLow:
+		return _queue.QueueLow
+	default:
+		return _queue.QueueDefault
+	}
+}
+
+var (
+	ErrExecutionNotFound         = errors.New("execution not found")
+	ErrExecutionNotCancellable   = errors.New("execution already finished")
+	ErrExecutionNotReschedulable = errors.New("execution is not scheduled")
+	// ErrIdempotentReplay is returned along with the original execution when a dispatch reuses an idempotency key.
+	ErrIdempotentReplay = errors.New("idempotency key already used")
+	// ErrIdempotencyKeyMismatch is returned when an idempotency key is reused for another command or device.
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key already used for another dispatch")
+)
+
+// defaultIdempotencyWindow is how long an idempotency key returns the original execution,
+// unless overridden by the IDEMPOTENCY_WINDOW environment variable.
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// DispatchOptions tunes how a command execution is enqueued.
+type DispatchOptions struct {
+	ProcessAt      *time.Time        // Dispatch at this time instead of immediately
+	IdempotencyKey string            // Replays with the same key return the original execution
+	BatchID        string            // Batch the execution belongs to
+	Rollout        *db.RolloutPolicy // Dispatch a batch in waves
+	GroupID        string            // Device group the devices of a batch were resolved from
+	Selector       string            // Tag selector the devices of a batch were resolved from
+}
+
 type TaskWorker interface {
 	Generate(models.CommandCreateDTO) (*asynq.Task, error)
 	Process(context.Context, *asynq.Task) error
+	RetryDelay(int, error, *asynq.Task) time.Duration
 	JobName() string
 }
 
 // commandWorker implements TaskWorker (compile-time assertion)
 var commandWorker TaskWorker = NewCommandWorker(TypeCommandExecutionJob)
 
-// Init starts the asynq server and registers all domain worker handlers.
+var scheduleWorker = NewScheduleWorker(TypeCommandScheduleJob)
+
+var rolloutWorker = NewRolloutWorker(TypeBatchRolloutJob)
+
+var sweepWorker = NewSweepWorker(TypeResponseSweepJob)
+
+var requeueWorker = NewRequeueWorker(TypeCommandRequeueJob)
+
+// Init starts the asynq server and the command scheduler, and registers all domain worker handlers.
 func Init() {
 	srv := _queue.GetQueueServer()
 	mux := asynq.NewServeMux()
 
 	mux.HandleFunc(commandWorker.JobName(), commandWorker.Process)
+	_queue.RegisterRetryDelayFunc(commandWorker.JobName(), commandWorker.RetryDelay)
+	_queue.RegisterNotFailure(errDeviceBusy)
+	_queue.RegisterNotFailure(errDeferred)
+	mux.HandleFunc(scheduleWorker.JobName(), scheduleWorker.Process)
+	mux.HandleFunc(rolloutWorker.JobName(), rolloutWorker.Process)
+	mux.HandleFunc(sweepWorker.JobName(), sweepWorker.Process)
+	mux.HandleFunc(requeueWorker.JobName(), requeueWorker.Process)
+
+	// Device responses are received on shared wildcard subscriptions and routed to the waiting tasks
+	if err := responses.subscribe(); err != nil {
+		log.Fatalf("Could not subscribe to device responses: %v", err)
+	}
+
+	_queue.InitPeriodicTaskManager(_queue.RedisOpt, &scheduleConfigProvider{worker: scheduleWorker, sweeper: sweepWorker}, scheduleSyncInterval)
+	if err := _queue.GetPeriodicTaskManager().Start(); err != nil {
+		log.Fatalf("Could not start command scheduler: %v", err)
+	}
+	defer _queue.ClosePeriodicTaskManager()
 
 	log.Info("Worker server starting...")
-	if err := srv.Run(mux); err != nil {
+	if err := srv.Start(mux); err != nil {
 		log.Fatalf("Could not run worker server: %v", err)
 	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	<-sigs
+	// Stop the tasks waiting for devices first, so they are requeued rather than abandoned, see interrupt.go
+	log.Info("Worker server shutting down...")
+	stop(errShuttingDown)
+	srv.Shutdown()
+	// Announces the replica offline, which the broker does not do on a clean disconnect
+	_mqtt.GetClient().Disconnect(250)
 }
 
-// EnqueueTask enqueues a pre-built task.
-func EnqueueTask(task *asynq.Task) error {
-	_, err := _queue.GetQueueClient().Enqueue(task)
+// EnqueueTask enqueues a pre-built task. Options override the ones the task was built with.
+func EnqueueTask(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	info, err := _queue.GetQueueClient().Enqueue(task, opts...)
 	if err != nil {
 		log.Errorf("Could not enqueue task: %v", err)
-		return err
+		return nil, err
 	}
-	return nil
+	return info, nil
 }
 
-// EnqueueCommandExecutionTask generates and enqueues a command execution task using the singleton worker.
-func EnqueueCommandExecutionTask(dto models.CommandCreateDTO) error {
+// EnqueueCommandExecutionTask records a new execution and enqueues its task using the singleton worker.
+// The execution ID is used as the asynq task ID so both can be looked up with the same identifier.
+// When the idempotency key of the options was already used within the idempotency window,
+// nothing is enqueued and the original execution is returned with ErrIdempotentReplay.
+// Executions for offline devices follow the offline policy of the command config: rejected executions
+// are recorded as FAILED without being enqueued, deferred ones wait for ReleaseDeferredExecutions.
+func EnqueueCommandExecutionTask(dto models.CommandCreateDTO, opts DispatchOptions) (*db.CommandExecution, error) {
 	t, err := commandWorker.Generate(dto)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	repo := NewExecutionRepository(db.GetDB())
+	execution := &db.CommandExecution{
+		DeviceID:        dto.DeviceID,
+		CommandConfigID: dto.CommandConfigID,
+		Status:          db.ExecutionStatusPending,
+		Queue:           priorityQueue(dto.Priority),
+		Command:         t.Payload(),
+	}
+	if opts.ProcessAt != nil && opts.ProcessAt.After(time.Now()) {
+		execution.Status = db.ExecutionStatusScheduled
+		execution.ScheduledAt = opts.ProcessAt
+	}
+	if opts.BatchID != "" {
+		execution.BatchID = &opts.BatchID
+	}
+	enqueue := applyOfflinePolicy(execution, dto)
+	if opts.IdempotencyKey != "" {
+		if original, err := findIdempotentExecution(repo, dto, opts.IdempotencyKey); original != nil || err != nil {
+			return original, err
+		}
+		execution.IdempotencyKey = &opts.IdempotencyKey
+	}
+	if err := repo.Create(execution); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) && opts.IdempotencyKey != "" {
+			// A concurrent request with the same key won the race
+			return findIdempotentExecution(repo, dto, opts.IdempotencyKey)
+		}
+		log.Errorf("Could not record command execution: %v", err)
+		return nil, err
+	}
+	if !enqueue {
+		log.Infof("Rejected command execution %s: device %s is offline", execution.ID, dto.DeviceID)
+		return execution, nil
+	}
+
+	enqueueOpts := []asynq.Option{asynq.TaskID(execution.ID), asynq.Queue(execution.Queue)}
+	queued := false
+	if execution.ScheduledAt != nil {
+		enqueueOpts = append(enqueueOpts, asynq.ProcessAt(*execution.ScheduledAt))
+	} else if execution.DeferredUntil != nil {
+		enqueueOpts = append(enqueueOpts, asynq.ProcessAt(*execution.DeferredUntil))
+	} else if !dto.AllowConcurrent {
+		// Take a place behind the commands already dispatched to the device, see serialization.go.
+		// Failing that, the execution takes its place when its task is picked up.
+		if err := queueOnDevice(dto.DeviceID, execution.ID); err != nil {
+			log.Warnf("Could not queue execution %s on its device: %v", execution.ID, err)
+		} else {
+			queued = true
+		}
+	}
+	if _, err := EnqueueTask(t, enqueueOpts...); err != nil {
+		if queued {
+			releaseDevice(dto.DeviceID, execution.ID)
+		}
+		event := db.ExecutionEvent{Status: db.ExecutionStatusFailed, Message: err.Error()}
+		if err := repo.UpdateStatus(execution.ID, event, map[string]any{"error": err.Error()}); err != nil {
+			log.Errorf("Could not update command execution %s: %v", execution.ID, err)
+		}
+		return nil, err
+	}
+	return execution, nil
+}
+
+// FindIdempotentExecution returns the execution dispatched with the idempotency key within the idempotency window
+// along with ErrIdempotentReplay, or nil if the key is unused, so a replayed request is answered before the checks
+// that only apply to new dispatches.
+func FindIdempotentExecution(dto models.CommandCreateDTO, key string) (*db.CommandExecution, error) {
+	return findIdempotentExecution(NewExecutionRepository(db.GetDB()), dto, key)
+}
+
+// findIdempotentExecution returns the execution dispatched with the idempotency key within the idempotency window.
+// A key older than the window is released so it can be used again.
+// Keys are shared with batches: a key a batch was dispatched with cannot dispatch a single execution.
+func findIdempotentExecution(repo *ExecutionRepository, dto models.CommandCreateDTO, key string) (*db.CommandExecution, error) {
+	original, err := repo.FindByIdempotencyKey(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		used, err := NewBatchRepository(repo.db).HasIdempotencyKey(key, time.Now().Add(-idempotencyWindow()))
+		if used {
+			return nil, ErrIdempotencyKeyMismatch
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(original.IssuedAt) > idempotencyWindow() {
+		return nil, repo.ReleaseIdempotencyKey(original.ID)
+	}
+	if original.DeviceID != dto.DeviceID || original.CommandConfigID != dto.CommandConfigID {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+	return original, ErrIdempotentReplay
+}
+
+// idempotencyWindow reads the IDEMPOTENCY_WINDOW environment variable (e.g. "1h").
+func idempotencyWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_WINDOW"))
+	if err != nil || window <= 0 {
+		return defaultIdempotencyWindow
+	}
+	return window
+}
+
+// CancelCommandExecution cancels an execution that has not finished yet.
+// Queued tasks are deleted from the queue; in-flight tasks have their processing cancelled
+// and the device is asked to abort the command.
+func CancelCommandExecution(id string) (*db.CommandExecution, error) {
+	repo := NewExecutionRepository(db.GetDB())
+	execution, err := repo.FindByID(id)
+	if err != nil {
+		return nil, ErrExecutionNotFound
+	}
+
+	// Mark the execution first so the worker sees the cancellation whatever state the task is in
+	event := db.ExecutionEvent{Status: db.ExecutionStatusCancelled, Message: "cancelled by operator"}
+	cancelled, err := repo.Cancel(id, event, map[string]any{"completed_at": time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrExecutionNotCancellable
+	}
+
+	inspector := _queue.GetQueueInspector()
+	if err := inspector.DeleteTask(execution.Queue, id); err != nil {
+		// The task is active (or already gone): stop the worker and tell the device
+		if err := inspector.CancelProcessing(id); err != nil {
+			log.Errorf("Could not cancel processing of task %s: %v", id, err)
+		}
+		if err := publishCancel(execution.DeviceID, id); err != nil {
+			log.Errorf("Could not publish cancellation to device %s, task %s: %v", execution.DeviceID, id, err)
+		}
+	} else {
+		// An event-driven command is on the device although its task completed
+		if execution.AwaitsResponse() {
+			if err := publishCancel(execution.DeviceID, id); err != nil {
+				log.Errorf("Could not publish cancellation to device %s, task %s: %v", execution.DeviceID, id, err)
+			}
+		}
+		// A task waiting for a retry may hold its device
+		releaseDevice(execution.DeviceID, id)
+	}
+
+	return repo.FindByID(id)
+}
+
+// RescheduleCommandExecution moves a scheduled execution to another time, as long as it has not fired yet.
+func RescheduleCommandExecution(id string, at time.Time) (*db.CommandExecution, error) {
+	repo := NewExecutionRepository(db.GetDB())
+	execution, err := repo.FindByID(id)
+	if err != nil {
+		return nil, ErrExecutionNotFound
+	}
+	if execution.Status != db.ExecutionStatusScheduled {
+		return nil, ErrExecutionNotReschedulable
+	}
+
+	inspector := _queue.GetQueueInspector()
+	info, err := inspector.GetTaskInfo(execution.Queue, id)
+	if err != nil || info.State != asynq.TaskStateScheduled {
+		return nil, ErrExecutionNotReschedulable
+	}
+	var dto models.CommandCreateDTO
+	if err := json.Unmarshal(info.Payload, &dto); err != nil {
+		return nil, err
+	}
+	t, err := commandWorker.Generate(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	// asynq cannot move a scheduled task, so replace it with the same ID.
+	// Deleting fails if the task fired in the meantime.
+	if err := inspector.DeleteTask(execution.Queue, id); err != nil {
+		return nil, ErrExecutionNotReschedulable
+	}
+	if _, err := EnqueueTask(t, asynq.TaskID(id), asynq.Queue(execution.Queue), asynq.ProcessAt(at)); err != nil {
+		event := db.ExecutionEvent{Status: db.ExecutionStatusFailed, Message: err.Error()}
+		if err := repo.UpdateStatus(id, event, map[string]any{"error": err.Error(), "completed_at": time.Now()}); err != nil {
+			log.Errorf("Could not update command execution %s: %v", id, err)
+		}
+		return nil, err
+	}
+
+	event := db.ExecutionEvent{Status: db.ExecutionStatusScheduled, Message: "rescheduled to " + at.Format(time.RFC3339)}
+	if err := repo.UpdateStatus(id, event, map[string]any{"scheduled_at": at}); err != nil {
+		return nil, err
 	}
-	return EnqueueTask(t)
+	return repo.FindByID(id)
 }
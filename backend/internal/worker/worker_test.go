@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"command-dispatcher/internal/config/_queue"
+	"command-dispatcher/internal/config/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	tests := []struct {
+		priority string
+		expected string
+	}{
+		{priority: db.PriorityHigh, expected: _queue.QueueCritical},
+		{priority: db.PriorityNormal, expected: _queue.QueueDefault},
+		{priority: db.PriorityLow, expected: _queue.QueueLow},
+		{priority: "", expected: _queue.QueueDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			assert.Equal(t, tt.expected, priorityQueue(tt.priority))
+		})
+	}
+}
+
+func TestIdempotencyWindow(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: defaultIdempotencyWindow},
+		{value: "1h", expected: time.Hour},
+		{value: "90m", expected: 90 * time.Minute},
+		{value: "0s", expected: defaultIdempotencyWindow},
+		{value: "-1h", expected: defaultIdempotencyWindow},
+		{value: "one day", expected: defaultIdempotencyWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("IDEMPOTENCY_WINDOW", tt.value)
+			assert.Equal(t, tt.expected, idempotencyWindow())
+		})
+	}
+}